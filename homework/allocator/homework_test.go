@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"reflect"
+	"sort"
 	"testing"
 	"unsafe"
 
@@ -10,23 +12,411 @@ import (
 
 // go test -v homework_test.go
 
+var (
+	ErrObjectOutOfMemory  = errors.New("object is outside of memory")
+	ErrObjectsOverlap     = errors.New("objects overlap")
+	ErrIncorrectSize      = errors.New("incorrect object size")
+	ErrIncorrectAlignment = errors.New("incorrect object alignment")
+	ErrDanglingPointer    = errors.New("pointer does not belong to any object")
+)
+
+// Object describes a live object inside memory which
+// must be preserved during compaction.
+type Object struct {
+	Pointer unsafe.Pointer
+	Size    int
+	Align   int  // power of two, zero means one
+	Pinned  bool // object must not be moved
+}
+
+type CompactionResult struct {
+	Reclaimed     int // bytes released between the old and the new high-water mark
+	HighWaterMark int // offset right after the last live object
+}
+
+// Defragment compacts one-byte objects. Several pointers
+// to the same byte are treated as pointers to one object.
 func Defragment(memory []byte, pointers []unsafe.Pointer) {
 	if len(memory) == 0 || len(pointers) == 0 {
 		return
 	}
-	for i := range pointers {
-		currentLocation := unsafe.Pointer(&memory[i])
-		if currentLocation == pointers[i] {
+
+	unique := make(map[unsafe.Pointer]struct{}, len(pointers))
+	objects := make([]Object, 0, len(pointers))
+	for _, pointer := range pointers {
+		if _, found := unique[pointer]; found {
 			continue
 		}
 
-		// Вычисляем смещение  между фактическим и ожидаемым местом в памяти.
-		offset := int(uintptr(pointers[i]) - uintptr(currentLocation))
-		// Меняем местами значения памяти.
-		memory[i], memory[i+offset] = memory[i+offset], memory[i]
-		// Обновляем значение указателя на новую позицию.
-		pointers[i] = currentLocation
+		unique[pointer] = struct{}{}
+		objects = append(objects, Object{Pointer: pointer, Size: 1, Align: 1})
 	}
+
+	_, _ = Compact(memory, objects, pointers)
+}
+
+// Compact slides objects to the beginning of memory keeping their order,
+// contents and alignment. Pinned objects stay in place and the following
+// objects are moved right after them. Object descriptors and every pointer
+// into an object (not only to its first byte) are rewritten in place.
+// Memory is not modified if any object or pointer is incorrect.
+func Compact(memory []byte, objects []Object, pointers []unsafe.Pointer) (CompactionResult, error) {
+	if len(objects) == 0 {
+		return CompactionResult{}, nil
+	}
+
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(memory)))
+	order, err := sortObjects(memory, objects)
+	if err != nil {
+		return CompactionResult{}, err
+	}
+
+	oldOffsets := make([]int, len(order))
+	for idx, objectIdx := range order {
+		oldOffsets[idx] = int(uintptr(objects[objectIdx].Pointer) - base)
+	}
+
+	// pointers are resolved before moving anything
+	// to leave memory untouched in case of an error
+	owners := make([]int, len(pointers))
+	for idx, pointer := range pointers {
+		offset := int(uintptr(pointer) - base)
+		position := sort.Search(len(oldOffsets), func(i int) bool {
+			return oldOffsets[i] > offset
+		}) - 1
+
+		if uintptr(pointer) < base || position < 0 || offset >= oldOffsets[position]+objects[order[position]].Size {
+			return CompactionResult{}, ErrDanglingPointer
+		}
+
+		owners[idx] = position
+	}
+
+	newOffsets := make([]int, len(order))
+	cursor := 0
+	for idx, objectIdx := range order {
+		object := &objects[objectIdx]
+		newOffset := alignUp(base+uintptr(cursor), alignment(object.Align)) - base
+		if object.Pinned {
+			newOffset = uintptr(oldOffsets[idx])
+		}
+
+		copy(memory[newOffset:], memory[oldOffsets[idx]:oldOffsets[idx]+object.Size])
+		clear(memory[cursor:newOffset]) // padding
+
+		newOffsets[idx] = int(newOffset)
+		object.Pointer = unsafe.Pointer(&memory[newOffset])
+		cursor = int(newOffset) + object.Size
+	}
+
+	last := len(order) - 1
+	oldHighWaterMark := oldOffsets[last] + objects[order[last]].Size
+	clear(memory[cursor:oldHighWaterMark])
+
+	for idx, pointer := range pointers {
+		position := owners[idx]
+		shift := oldOffsets[position] - newOffsets[position]
+		pointers[idx] = unsafe.Add(pointer, -shift)
+	}
+
+	return CompactionResult{
+		Reclaimed:     oldHighWaterMark - cursor,
+		HighWaterMark: cursor,
+	}, nil
+}
+
+// sortObjects validates objects and returns
+// their indexes ordered by address.
+func sortObjects(memory []byte, objects []Object) ([]int, error) {
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(memory)))
+	order := make([]int, len(objects))
+	for idx, object := range objects {
+		if object.Size <= 0 {
+			return nil, ErrIncorrectSize
+		}
+
+		align := alignment(object.Align)
+		if object.Align < 0 || align&(align-1) != 0 || uintptr(object.Pointer)%align != 0 {
+			return nil, ErrIncorrectAlignment
+		}
+
+		address := uintptr(object.Pointer)
+		if address < base || address-base+uintptr(object.Size) > uintptr(len(memory)) {
+			return nil, ErrObjectOutOfMemory
+		}
+
+		order[idx] = idx
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return uintptr(objects[order[i]].Pointer) < uintptr(objects[order[j]].Pointer)
+	})
+
+	for idx := 1; idx < len(order); idx++ {
+		previous, current := objects[order[idx-1]], objects[order[idx]]
+		if uintptr(previous.Pointer)+uintptr(previous.Size) > uintptr(current.Pointer) {
+			return nil, ErrObjectsOverlap
+		}
+	}
+
+	return order, nil
+}
+
+func alignment(align int) uintptr {
+	if align == 0 {
+		return 1
+	}
+
+	return uintptr(align)
+}
+
+func alignUp(address uintptr, align uintptr) uintptr {
+	return (address + align - 1) &^ (align - 1)
+}
+
+var (
+	ErrIncorrectCapacity = errors.New("incorrect capacity")
+	ErrNotEnoughMemory   = errors.New("not enough memory")
+	ErrInvalidHandle     = errors.New("invalid handle")
+	ErrHandlePinned      = errors.New("handle is pinned")
+	ErrHandleNotPinned   = errors.New("handle is not pinned")
+)
+
+// Handle stays valid while data is moved by compaction,
+// the upper half is a generation to catch stale handles.
+// Generations start from 1, so the zero Handle is never valid.
+type Handle uint64
+
+func newHandle(index int, generation uint32) Handle {
+	return Handle(uint64(generation)<<32 | uint64(index))
+}
+
+func (h Handle) index() int {
+	return int(uint32(h))
+}
+
+func (h Handle) generation() uint32 {
+	return uint32(h >> 32)
+}
+
+type block struct {
+	offset     int
+	size       int
+	align      int
+	pins       int
+	generation uint32
+	live       bool
+}
+
+type FragmentationStats struct {
+	Capacity      int
+	LiveBytes     int
+	LiveBlocks    int
+	PinnedBlocks  int
+	HighWaterMark int
+	HoleBytes     int     // free bytes below the high-water mark
+	Fragmentation float64 // share of free memory unavailable for bump allocation
+}
+
+// RelocatableAllocator hands out handles instead of pointers, so
+// blocks can be moved by Compact. A pointer obtained by Pin stays
+// valid until the corresponding Unpin.
+type RelocatableAllocator struct {
+	memory        []byte
+	highWaterMark int
+	blocks        []block
+	freeHandles   []int
+}
+
+func NewRelocatableAllocator(capacity int) (*RelocatableAllocator, error) {
+	if capacity <= 0 {
+		return nil, ErrIncorrectCapacity
+	}
+
+	return &RelocatableAllocator{
+		memory: make([]byte, capacity),
+	}, nil
+}
+
+// Allocate compacts memory if there is no space
+// after the high-water mark and tries again.
+func (a *RelocatableAllocator) Allocate(size int, align int) (Handle, error) {
+	if size <= 0 {
+		return 0, ErrIncorrectSize
+	}
+
+	if align < 0 || alignment(align)&(alignment(align)-1) != 0 {
+		return 0, ErrIncorrectAlignment
+	}
+
+	offset, ok := a.reserve(size, align)
+	if !ok {
+		if _, err := a.Compact(); err != nil {
+			return 0, err
+		}
+
+		if offset, ok = a.reserve(size, align); !ok {
+			return 0, ErrNotEnoughMemory
+		}
+	}
+
+	index := len(a.blocks)
+	if len(a.freeHandles) != 0 {
+		index = a.freeHandles[len(a.freeHandles)-1]
+		a.freeHandles = a.freeHandles[:len(a.freeHandles)-1]
+	} else {
+		a.blocks = append(a.blocks, block{generation: 1})
+	}
+
+	current := &a.blocks[index]
+	current.offset = offset
+	current.size = size
+	current.align = align
+	current.live = true
+	return newHandle(index, current.generation), nil
+}
+
+func (a *RelocatableAllocator) reserve(size int, align int) (int, bool) {
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(a.memory)))
+	offset := int(alignUp(base+uintptr(a.highWaterMark), alignment(align)) - base)
+	if offset+size > len(a.memory) {
+		return 0, false
+	}
+
+	a.highWaterMark = offset + size
+	return offset, true
+}
+
+func (a *RelocatableAllocator) Deallocate(handle Handle) error {
+	current, err := a.block(handle)
+	if err != nil {
+		return err
+	}
+
+	if current.pins != 0 {
+		return ErrHandlePinned
+	}
+
+	clear(a.memory[current.offset : current.offset+current.size])
+	if current.offset+current.size == a.highWaterMark {
+		a.highWaterMark = current.offset
+	}
+
+	generation := current.generation + 1
+	if generation == 0 {
+		generation = 1 // skipped on overflow
+	}
+
+	*current = block{generation: generation}
+	a.freeHandles = append(a.freeHandles, handle.index())
+	return nil
+}
+
+// Pin fixes a block in place and returns its current address.
+// Pins are counted, every Pin must be paired with Unpin.
+func (a *RelocatableAllocator) Pin(handle Handle) (unsafe.Pointer, error) {
+	current, err := a.block(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	current.pins++
+	return unsafe.Pointer(&a.memory[current.offset]), nil
+}
+
+func (a *RelocatableAllocator) Unpin(handle Handle) error {
+	current, err := a.block(handle)
+	if err != nil {
+		return err
+	}
+
+	if current.pins == 0 {
+		return ErrHandleNotPinned
+	}
+
+	current.pins--
+	return nil
+}
+
+// Compact moves unpinned blocks to the beginning
+// of memory and updates the handle table.
+func (a *RelocatableAllocator) Compact() (CompactionResult, error) {
+	indexes := make([]int, 0, len(a.blocks))
+	objects := make([]Object, 0, len(a.blocks))
+	for idx, current := range a.blocks {
+		if !current.live {
+			continue
+		}
+
+		indexes = append(indexes, idx)
+		objects = append(objects, Object{
+			Pointer: unsafe.Pointer(&a.memory[current.offset]),
+			Size:    current.size,
+			Align:   current.align,
+			Pinned:  current.pins != 0,
+		})
+	}
+
+	if len(objects) == 0 {
+		result := CompactionResult{Reclaimed: a.highWaterMark}
+		a.highWaterMark = 0
+		return result, nil
+	}
+
+	result, err := Compact(a.memory, objects, nil)
+	if err != nil {
+		return CompactionResult{}, err
+	}
+
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(a.memory)))
+	for idx, object := range objects {
+		a.blocks[indexes[idx]].offset = int(uintptr(object.Pointer) - base)
+	}
+
+	// old high-water mark could be after freed blocks
+	result.Reclaimed = a.highWaterMark - result.HighWaterMark
+	a.highWaterMark = result.HighWaterMark
+	return result, nil
+}
+
+func (a *RelocatableAllocator) Stats() FragmentationStats {
+	stats := FragmentationStats{
+		Capacity:      len(a.memory),
+		HighWaterMark: a.highWaterMark,
+	}
+
+	for _, current := range a.blocks {
+		if !current.live {
+			continue
+		}
+
+		stats.LiveBlocks++
+		stats.LiveBytes += current.size
+		if current.pins != 0 {
+			stats.PinnedBlocks++
+		}
+	}
+
+	stats.HoleBytes = stats.HighWaterMark - stats.LiveBytes
+	if free := stats.Capacity - stats.LiveBytes; free != 0 {
+		stats.Fragmentation = float64(stats.HoleBytes) / float64(free)
+	}
+
+	return stats
+}
+
+func (a *RelocatableAllocator) block(handle Handle) (*block, error) {
+	index := handle.index()
+	if index >= len(a.blocks) {
+		return nil, ErrInvalidHandle
+	}
+
+	current := &a.blocks[index]
+	if !current.live || current.generation != handle.generation() {
+		return nil, ErrInvalidHandle
+	}
+
+	return current, nil
 }
 
 func TestDefragmentation(t *testing.T) {
@@ -62,3 +452,195 @@ func TestDefragmentation(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(defragmentedMemory, fragmentedMemory))
 	assert.True(t, reflect.DeepEqual(defragmentedPointers, fragmentedPointers))
 }
+
+func TestDefragmentationWithAdjacentObjects(t *testing.T) {
+	memory := []byte{0x00, 0x00, 0x01, 0x02, 0x00, 0x03}
+	pointers := []unsafe.Pointer{
+		unsafe.Pointer(&memory[2]),
+		unsafe.Pointer(&memory[3]),
+		unsafe.Pointer(&memory[5]),
+		unsafe.Pointer(&memory[3]),
+	}
+
+	Defragment(memory, pointers)
+	assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x00, 0x00, 0x00}, memory)
+	assert.Equal(t, []unsafe.Pointer{
+		unsafe.Pointer(&memory[0]),
+		unsafe.Pointer(&memory[1]),
+		unsafe.Pointer(&memory[2]),
+		unsafe.Pointer(&memory[1]),
+	}, pointers)
+}
+
+func TestCompaction(t *testing.T) {
+	memory := make([]byte, 64)
+	base := uintptr(unsafe.Pointer(&memory[0]))
+	offset := func(pointer unsafe.Pointer) int {
+		return int(uintptr(pointer) - base)
+	}
+
+	// offsets are chosen relative to an 8-byte aligned base
+	first := alignUp(base, 8) - base
+	objects := []Object{
+		{Pointer: unsafe.Pointer(&memory[first+24]), Size: 4, Align: 4},
+		{Pointer: unsafe.Pointer(&memory[first+8]), Size: 3, Align: 1},
+		{Pointer: unsafe.Pointer(&memory[first+40]), Size: 8, Align: 8},
+	}
+
+	copy(memory[first+8:], []byte{1, 2, 3})
+	copy(memory[first+24:], []byte{4, 5, 6, 7})
+	copy(memory[first+40:], []byte{8, 9, 10, 11, 12, 13, 14, 15})
+
+	pointers := []unsafe.Pointer{
+		unsafe.Pointer(&memory[first+40]),
+		unsafe.Pointer(&memory[first+26]), // inside of the object
+		unsafe.Pointer(&memory[first+8]),
+		unsafe.Pointer(&memory[first+47]),
+	}
+
+	result, err := Compact(memory, objects, pointers)
+	assert.NoError(t, err)
+	assert.Equal(t, CompactionResult{Reclaimed: 32, HighWaterMark: int(first) + 16}, result)
+
+	assert.Equal(t, int(first)+4, offset(objects[0].Pointer))
+	assert.Equal(t, int(first), offset(objects[1].Pointer))
+	assert.Equal(t, int(first)+8, offset(objects[2].Pointer))
+
+	assert.Equal(t, []int{int(first) + 8, int(first) + 6, int(first), int(first) + 15}, []int{
+		offset(pointers[0]), offset(pointers[1]), offset(pointers[2]), offset(pointers[3]),
+	})
+
+	expected := make([]byte, 64)
+	copy(expected[first:], []byte{1, 2, 3, 0, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	assert.Equal(t, expected, memory)
+}
+
+func TestCompactionWithIncorrectArguments(t *testing.T) {
+	memory := make([]byte, 16)
+	tests := map[string]struct {
+		objects  []Object
+		pointers []unsafe.Pointer
+		err      error
+	}{
+		"incorrect size": {
+			objects: []Object{{Pointer: unsafe.Pointer(&memory[2]), Size: 0}},
+			err:     ErrIncorrectSize,
+		},
+		"incorrect alignment": {
+			objects: []Object{{Pointer: unsafe.Pointer(&memory[2]), Size: 1, Align: 3}},
+			err:     ErrIncorrectAlignment,
+		},
+		"object out of memory": {
+			objects: []Object{{Pointer: unsafe.Pointer(&memory[12]), Size: 8}},
+			err:     ErrObjectOutOfMemory,
+		},
+		"overlapped objects": {
+			objects: []Object{
+				{Pointer: unsafe.Pointer(&memory[4]), Size: 4},
+				{Pointer: unsafe.Pointer(&memory[2]), Size: 4},
+			},
+			err: ErrObjectsOverlap,
+		},
+		"dangling pointer": {
+			objects:  []Object{{Pointer: unsafe.Pointer(&memory[4]), Size: 4}},
+			pointers: []unsafe.Pointer{unsafe.Pointer(&memory[8])},
+			err:      ErrDanglingPointer,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			memory[4] = 0xFF
+			_, err := Compact(memory, test.objects, test.pointers)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, byte(0xFF), memory[4])
+		})
+	}
+}
+
+func TestRelocatableAllocator(t *testing.T) {
+	allocator, err := NewRelocatableAllocator(32)
+	assert.NoError(t, err)
+
+	handle1, err := allocator.Allocate(8, 8)
+	assert.NoError(t, err)
+	handle2, err := allocator.Allocate(8, 8)
+	assert.NoError(t, err)
+	handle3, err := allocator.Allocate(8, 8)
+	assert.NoError(t, err)
+
+	pointer, err := allocator.Pin(handle3)
+	assert.NoError(t, err)
+	*(*int64)(pointer) = 300
+	assert.NoError(t, allocator.Unpin(handle3))
+
+	var unset Handle
+	assert.NotEqual(t, unset, handle1)
+	assert.ErrorIs(t, allocator.Deallocate(unset), ErrInvalidHandle)
+	_, err = allocator.Pin(unset)
+	assert.ErrorIs(t, err, ErrInvalidHandle)
+
+	assert.NoError(t, allocator.Deallocate(handle1))
+	assert.ErrorIs(t, allocator.Deallocate(handle1), ErrInvalidHandle)
+
+	stats := allocator.Stats()
+	assert.Equal(t, 16, stats.LiveBytes)
+	assert.Equal(t, 8, stats.HoleBytes)
+
+	// there is no space after the high-water mark, so
+	// the allocator has to compact memory by itself
+	handle4, err := allocator.Allocate(16, 8)
+	assert.NoError(t, err)
+
+	pointer, err = allocator.Pin(handle3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(300), *(*int64)(pointer))
+	assert.NoError(t, allocator.Unpin(handle3))
+
+	_, err = allocator.Allocate(1, 1)
+	assert.ErrorIs(t, err, ErrNotEnoughMemory)
+
+	assert.NoError(t, allocator.Deallocate(handle2))
+	assert.NoError(t, allocator.Deallocate(handle3))
+	assert.NoError(t, allocator.Deallocate(handle4))
+	assert.Equal(t, 0, allocator.Stats().LiveBlocks)
+}
+
+func TestRelocatableAllocatorWithPinnedBlocks(t *testing.T) {
+	allocator, err := NewRelocatableAllocator(32)
+	assert.NoError(t, err)
+
+	handle1, _ := allocator.Allocate(8, 8)
+	handle2, _ := allocator.Allocate(8, 8)
+	handle3, _ := allocator.Allocate(8, 8)
+	_ = allocator.Deallocate(handle1)
+
+	pinned, err := allocator.Pin(handle2)
+	assert.NoError(t, err)
+	*(*int64)(pinned) = 200
+	assert.ErrorIs(t, allocator.Deallocate(handle2), ErrHandlePinned)
+
+	// the hole before the pinned block can not be reclaimed
+	result, err := allocator.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, CompactionResult{Reclaimed: 0, HighWaterMark: allocator.Stats().HighWaterMark}, result)
+
+	pointer, _ := allocator.Pin(handle2)
+	assert.Equal(t, pinned, pointer)
+	assert.NoError(t, allocator.Unpin(handle2))
+	assert.NoError(t, allocator.Unpin(handle2))
+	assert.ErrorIs(t, allocator.Unpin(handle2), ErrHandleNotPinned)
+
+	result, err = allocator.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 8, result.Reclaimed)
+
+	pointer, _ = allocator.Pin(handle2)
+	assert.Equal(t, int64(200), *(*int64)(pointer))
+	assert.NoError(t, allocator.Unpin(handle2))
+
+	stats := allocator.Stats()
+	assert.Equal(t, 0, stats.HoleBytes)
+	assert.Equal(t, 0.0, stats.Fragmentation)
+	assert.NoError(t, allocator.Deallocate(handle3))
+}
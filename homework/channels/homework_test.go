@@ -1,6 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"runtime/debug"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -10,25 +18,989 @@ import (
 
 // go test -v homework_test.go
 
+const defaultQueueSize = 64
+
+var (
+	ErrPoolFull   = errors.New("pool is full")
+	ErrPoolClosed = errors.New("pool is closed")
+)
+
+type Option func(*WorkerPool)
+
+func WithQueueSize(size int) Option {
+	return func(pool *WorkerPool) {
+		pool.queueSize = size
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(pool *WorkerPool) {
+		pool.clock = clock
+	}
+}
+
+// WithErrorHandler receives errors of failed tasks and
+// *PanicError of panicked tasks, it is called by workers
+func WithErrorHandler(handler func(error)) Option {
+	return func(pool *WorkerPool) {
+		pool.onError = handler
+	}
+}
+
+// WithScaleHandler is called under the lock of the pool
+// on every start and stop of a worker caused by scaling
+func WithScaleHandler(handler func(ScaleEvent)) Option {
+	return func(pool *WorkerPool) {
+		pool.onScale = handler
+	}
+}
+
+// WithRetryPolicy retries tasks of AddTaskContext that return errors,
+// tasks that exhaust their attempts go to the dead-letter queue. The worker
+// is free during a backoff, the task is added to the end of the queue after
+// it or waits for one more backoff if the queue is full. Shutdown waits for
+// scheduled retries, ShutdownNow moves them to the dead-letter queue.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(pool *WorkerPool) {
+		policy.MaxAttempts = max(policy.MaxAttempts, 1)
+		pool.retry = &policy
+	}
+}
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration // delay before the second attempt, it doubles for next ones
+	MaxDelay    time.Duration // zero means no limit
+	Jitter      float64       // part of a delay in [0, 1] that is randomly cut off
+}
+
+// delay returns a backoff before the next attempt
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	limit := p.MaxDelay
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+
+	delay := min(p.BaseDelay, limit)
+	for i := 1; i < attempt; i++ {
+		if delay > limit/2 {
+			delay = limit
+			break
+		}
+
+		delay *= 2
+	}
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(delay))
+	}
+
+	return delay
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error of a task that must not be retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// DeadLetter is a task that failed all its attempts
+type DeadLetter struct {
+	Task     Task
+	Err      error // error of the last attempt
+	Attempts int
+}
+
+// WithHooks sets callbacks called by the pool without its lock,
+// they must be fast as they are called on every task. Hooks are not
+// ordered: OnEnqueue runs after the task is visible to workers, so
+// OnStart and OnFinish of the same task can run before it. Gauges
+// of queued and running tasks must be taken from Stats.
+func WithHooks(hooks Hooks) Option {
+	return func(pool *WorkerPool) {
+		pool.hooks = hooks
+	}
+}
+
+type Hooks struct {
+	OnEnqueue func()
+	OnStart   func(wait time.Duration)
+	OnFinish  func(run time.Duration, err error) // after every attempt, err is *PanicError for panicked tasks
+}
+
+var histogramBounds = [...]time.Duration{
+	time.Microsecond * 100,
+	time.Millisecond,
+	time.Millisecond * 10,
+	time.Millisecond * 100,
+	time.Second,
+	time.Second * 10,
+}
+
+// Histogram contains numbers of durations not greater than
+// bounds, the last count is for durations above all bounds
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []int64
+}
+
+type histogram struct {
+	counts [len(histogramBounds) + 1]atomic.Int64
+}
+
+func (h *histogram) observe(duration time.Duration) {
+	idx := 0
+	for idx < len(histogramBounds) && duration > histogramBounds[idx] {
+		idx++
+	}
+
+	h.counts[idx].Add(1)
+}
+
+func (h *histogram) snapshot() Histogram {
+	counts := make([]int64, len(h.counts))
+	for idx := range h.counts {
+		counts[idx] = h.counts[idx].Load()
+	}
+
+	return Histogram{
+		Bounds: append([]time.Duration(nil), histogramBounds[:]...),
+		Counts: counts,
+	}
+}
+
+type Stats struct {
+	Queued    int // including keyed tasks waiting for their turn
+	Running   int64
+	Retrying  int   // waiting for a backoff
+	Completed int64 // finished without a panic, failed tasks are included, retried attempts are not
+	Rejected  int64
+	Panicked  int64
+	WaitTime  Histogram // from adding to the start
+	RunTime   Histogram // of all attempts
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type Clock interface {
+	Now() time.Time
+	NewTimer(duration time.Duration) Timer
+}
+
+type realClock struct{}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(duration time.Duration) Timer {
+	return realTimer{Timer: time.NewTimer(duration)}
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type ScaleDirection int
+
+const (
+	ScaleUp ScaleDirection = iota
+	ScaleDown
+)
+
+type ScaleEvent struct {
+	Direction ScaleDirection
+	Workers   int // number of workers after scaling
+	Time      time.Time
+}
+
+const (
+	jobPending int32 = iota
+	jobRunning
+	jobCancelled
+)
+
+// PanicError keeps the value and the stack of a panicked task
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// Task is a unit of work returned by ShutdownNow, it
+// can be added back to a pool with AddTaskContext
+type Task func(ctx context.Context) error
+
+type job struct {
+	run     Task
+	ctx     context.Context
+	cancel  context.CancelFunc
+	state   atomic.Int32
+	onPanic func(err *PanicError)
+	onDrop  func() // replaces returning of the task by ShutdownNow
+	added   time.Time
+	attempt int
+	keyed   bool
+	key     string
+}
+
 type WorkerPool struct {
-	// need to implement
+	mutex        sync.Mutex
+	closed       bool
+	tasks        chan *job
+	tasksClosed  bool
+	keys         map[string][]*job // waiting tasks of keys with a queued or running task
+	keyedWaiting int
+	queueSize    int
+	stopped      bool // set by ShutdownNow, workers hand received jobs back
+	sent         int  // jobs sent to the channel
+	received     int  // jobs received from the channel
+	handedBack   []*job
+	handOff      *sync.Cond // signals received jobs to ShutdownNow
+	retrying     int
+	workers      sync.WaitGroup
+	ctx          context.Context // parent of contexts of all tasks
+	cancel       context.CancelFunc
+	workersCount int
+	idleWorkers  int
+	minWorkers   int
+	maxWorkers   int
+	idleTimeout  time.Duration
+	clock        Clock
+	onScale      func(ScaleEvent)
+	onError      func(error)
+	retry        *RetryPolicy
+	deadLetters  []DeadLetter
+	hooks        Hooks
+	panics       atomic.Int64
+	failures     atomic.Int64
+	running      atomic.Int64
+	completed    atomic.Int64
+	rejected     atomic.Int64
+	waitTime     histogram
+	runTime      histogram
 }
 
-func NewWorkerPool(workersNumber int) *WorkerPool {
-	// need to implement
-	return &WorkerPool{}
+// NewWorkerPool starts a fixed number of workers
+func NewWorkerPool(workersNumber int, options ...Option) *WorkerPool {
+	workersNumber = max(workersNumber, 1)
+	return newWorkerPool(workersNumber, workersNumber, 0, options)
 }
 
-// Return an error if the pool is full
+// NewAutoscalingWorkerPool starts extra workers up to the maximum when
+// queued tasks outnumber idle workers, a worker above the minimum
+// stops after it has been idle longer than the timeout
+func NewAutoscalingWorkerPool(minWorkers int, maxWorkers int, idleTimeout time.Duration, options ...Option) *WorkerPool {
+	minWorkers = max(minWorkers, 0)
+	maxWorkers = max(maxWorkers, minWorkers, 1)
+	return newWorkerPool(minWorkers, maxWorkers, idleTimeout, options)
+}
+
+func newWorkerPool(minWorkers int, maxWorkers int, idleTimeout time.Duration, options []Option) *WorkerPool {
+	pool := &WorkerPool{
+		queueSize:   defaultQueueSize,
+		minWorkers:  minWorkers,
+		maxWorkers:  maxWorkers,
+		idleTimeout: idleTimeout,
+		clock:       realClock{},
+		keys:        make(map[string][]*job),
+	}
+
+	for _, option := range options {
+		option(pool)
+	}
+
+	pool.tasks = make(chan *job, max(pool.queueSize, 0))
+	pool.handOff = sync.NewCond(&pool.mutex)
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+	pool.mutex.Lock()
+	for i := 0; i < minWorkers; i++ {
+		pool.startWorker()
+	}
+	pool.mutex.Unlock()
+
+	return pool
+}
+
+// Workers returns the current number of workers
+func (wp *WorkerPool) Workers() int {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+
+	return wp.workersCount
+}
+
+// startWorker must be called under the lock
+func (wp *WorkerPool) startWorker() {
+	wp.workersCount++
+	wp.idleWorkers++
+	wp.workers.Add(1)
+	go wp.work()
+}
+
+// scale must be called under the lock
+func (wp *WorkerPool) scale(direction ScaleDirection) {
+	if wp.onScale != nil {
+		wp.onScale(ScaleEvent{
+			Direction: direction,
+			Workers:   wp.workersCount,
+			Time:      wp.clock.Now(),
+		})
+	}
+}
+
+func (wp *WorkerPool) newJob(run Task) *job {
+	ctx, cancel := context.WithCancel(wp.ctx)
+	return &job{
+		run:    run,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Return an error if the pool is full or closed
 func (wp *WorkerPool) AddTask(task func()) error {
-	// need to implement
+	if task == nil {
+		return errors.New("incorrect task")
+	}
+
+	return wp.enqueue(wp.newJob(func(context.Context) error {
+		task()
+		return nil
+	}))
+}
+
+// AddTaskContext adds a task which can observe cancellation
+// and fail, errors are passed to the error handler
+func (wp *WorkerPool) AddTaskContext(task func(ctx context.Context) error) error {
+	if task == nil {
+		return errors.New("incorrect task")
+	}
+
+	return wp.enqueue(wp.newJob(task))
+}
+
+// AddKeyedTask runs tasks with the same key one by one in order of adding,
+// only one task of a key is in the queue at a time and the next one is added
+// to the end of the queue after it, so a hot key doesn't starve other tasks.
+// Waiting tasks of keys take places in the queue as usual tasks.
+func (wp *WorkerPool) AddKeyedTask(key string, task func()) error {
+	if task == nil {
+		return errors.New("incorrect task")
+	}
+
+	job := wp.newJob(func(context.Context) error {
+		task()
+		return nil
+	})
+
+	job.keyed, job.key = true, key
+	return wp.enqueue(job)
+}
+
+// DeadLetters returns tasks that failed all attempts of the retry
+// policy or failed with a permanent error, they are kept until replay
+func (wp *WorkerPool) DeadLetters() []DeadLetter {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+
+	return append([]DeadLetter(nil), wp.deadLetters...)
+}
+
+// ReplayDeadLetters adds dead tasks to the queue again and returns number
+// of replayed ones, the rest stays in the dead-letter queue on an error
+func (wp *WorkerPool) ReplayDeadLetters() (int, error) {
+	wp.mutex.Lock()
+	deadLetters := wp.deadLetters
+	wp.deadLetters = nil
+	wp.mutex.Unlock()
+
+	for idx, deadLetter := range deadLetters {
+		if err := wp.enqueue(wp.newJob(deadLetter.Task)); err != nil {
+			wp.mutex.Lock()
+			wp.deadLetters = append(deadLetters[idx:], wp.deadLetters...)
+			wp.mutex.Unlock()
+			return idx, err
+		}
+	}
+
+	return len(deadLetters), nil
+}
+
+// Stats returns a snapshot of counters, they are
+// updated independently and can be slightly inconsistent
+func (wp *WorkerPool) Stats() Stats {
+	wp.mutex.Lock()
+	queued := len(wp.tasks) + wp.keyedWaiting
+	retrying := wp.retrying
+	wp.mutex.Unlock()
+
+	return Stats{
+		Queued:    queued,
+		Running:   wp.running.Load(),
+		Retrying:  retrying,
+		Completed: wp.completed.Load(),
+		Rejected:  wp.rejected.Load(),
+		Panicked:  wp.panics.Load(),
+		WaitTime:  wp.waitTime.snapshot(),
+		RunTime:   wp.runTime.snapshot(),
+	}
+}
+
+// Panics returns number of panicked tasks
+func (wp *WorkerPool) Panics() int64 {
+	return wp.panics.Load()
+}
+
+// Failures returns number of tasks finished with an error
+func (wp *WorkerPool) Failures() int64 {
+	return wp.failures.Load()
+}
+
+func (wp *WorkerPool) enqueue(job *job) error {
+	job.added = wp.clock.Now()
+	if err := wp.push(job); err != nil {
+		// the context of a rejected job is detached from the pool
+		job.cancel()
+		wp.rejected.Add(1)
+		return err
+	}
+
+	// the task can be already started by a worker
+	if wp.hooks.OnEnqueue != nil {
+		wp.hooks.OnEnqueue()
+	}
+
+	return nil
+}
+
+func (wp *WorkerPool) push(job *job) error {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+
+	if wp.closed {
+		return ErrPoolClosed
+	}
+
+	if waiting, found := wp.keys[job.key]; job.keyed && found {
+		if len(wp.tasks)+wp.keyedWaiting >= cap(wp.tasks) {
+			return ErrPoolFull
+		}
+
+		wp.keys[job.key] = append(waiting, job)
+		wp.keyedWaiting++
+		return nil
+	}
+
+	if !wp.send(job) {
+		return ErrPoolFull
+	}
+
+	if job.keyed {
+		wp.keys[job.key] = nil
+	}
+
 	return nil
 }
 
-// Shutdown all workers and wait for all
-// tasks in the pool to complete
-func (wp *WorkerPool) Shutdown() {
-	// need to implement
+// send must be called under the lock
+func (wp *WorkerPool) send(job *job) bool {
+	// waiting keyed tasks reserve their places in the channel
+	if wp.keyedWaiting > 0 && len(wp.tasks)+wp.keyedWaiting >= cap(wp.tasks) {
+		return false
+	}
+
+	select {
+	case wp.tasks <- job:
+		wp.sent++
+	default:
+		return false
+	}
+
+	wp.scaleUp()
+	return true
+}
+
+// scaleUp must be called under the lock
+func (wp *WorkerPool) scaleUp() {
+	if len(wp.tasks) > wp.idleWorkers && wp.workersCount < wp.maxWorkers {
+		wp.startWorker()
+		wp.scale(ScaleUp)
+	}
+}
+
+// nextKeyed moves the next waiting task of the key to the
+// end of the queue, it must be called under the lock
+func (wp *WorkerPool) nextKeyed(key string) {
+	waiting, found := wp.keys[key]
+	switch {
+	case !found: // dropped by ShutdownNow
+	case len(waiting) == 0:
+		delete(wp.keys, key)
+	default:
+		// never blocks, the place is reserved
+		wp.tasks <- waiting[0]
+		wp.sent++
+		wp.keys[key] = waiting[1:]
+		wp.keyedWaiting--
+		wp.scaleUp()
+	}
+
+	if wp.closed {
+		wp.closeTasks()
+	}
+}
+
+// Shutdown stops accepting of new tasks and waits for running and
+// queued tasks until the end of the context, workers keep draining
+// the queue in the background if the context expires first
+func (wp *WorkerPool) Shutdown(ctx context.Context) error {
+	wp.close()
+
+	done := make(chan struct{})
+	go func() {
+		wp.workers.Wait()
+		// all tasks are finished, only the parent context is left
+		wp.cancel()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ShutdownNow stops accepting of new tasks, cancels contexts of running
+// tasks and returns queued tasks that have never been started, futures
+// of such tasks are completed with ErrPoolClosed instead. It doesn't wait
+// for running tasks, use Shutdown for it.
+func (wp *WorkerPool) ShutdownNow() []Task {
+	wp.mutex.Lock()
+	var waiting []*job
+	for key, jobs := range wp.keys {
+		waiting = append(waiting, jobs...)
+		delete(wp.keys, key)
+	}
+
+	wp.keyedWaiting = 0
+	wp.closed = true
+	wp.stopped = true
+	wp.closeTasks()
+	wp.mutex.Unlock()
+
+	// workers compete for the rest of the queue,
+	// but they hand received jobs back instead of running
+	var jobs []*job
+	for job := range wp.tasks {
+		jobs = append(jobs, job)
+	}
+
+	wp.mutex.Lock()
+	wp.received += len(jobs)
+	for wp.received < wp.sent {
+		wp.handOff.Wait()
+	}
+
+	jobs = append(wp.handedBack, jobs...)
+	wp.handedBack = nil
+	wp.mutex.Unlock()
+
+	// only started tasks are cancelled
+	wp.cancel()
+
+	var tasks []Task
+	// waiting keyed tasks go after the queued ones to keep the order of keys
+	for _, job := range append(jobs, waiting...) {
+		if job.state.CompareAndSwap(jobPending, jobCancelled) {
+			if job.onDrop != nil {
+				job.onDrop()
+			} else {
+				tasks = append(tasks, job.run)
+			}
+		}
+
+		job.cancel()
+	}
+
+	return tasks
+}
+
+func (wp *WorkerPool) close() {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+
+	wp.closed = true
+	wp.closeTasks()
+}
+
+// closeTasks closes the channel when no keyed tasks wait for their
+// turn and no retries are scheduled or when ShutdownNow stops the pool,
+// it must be called under the lock
+func (wp *WorkerPool) closeTasks() {
+	if !wp.tasksClosed && len(wp.keys) == 0 && (wp.retrying == 0 || wp.stopped) {
+		wp.tasksClosed = true
+		close(wp.tasks)
+	}
+}
+
+func (wp *WorkerPool) work() {
+	defer wp.workers.Done()
+
+	for {
+		job, ok := wp.wait()
+		if !ok {
+			return
+		}
+
+		survived := wp.execute(job)
+
+		wp.mutex.Lock()
+		wp.idleWorkers++
+		if job.keyed {
+			wp.nextKeyed(job.key)
+		}
+
+		if !survived {
+			// a fresh worker replaces the panicked one
+			wp.workers.Add(1)
+			go wp.work()
+		}
+		wp.mutex.Unlock()
+
+		if !survived {
+			return
+		}
+	}
+}
+
+// execute returns false if the task panicked
+func (wp *WorkerPool) execute(job *job) (survived bool) {
+	// job cancelled before start is never run
+	if !job.state.CompareAndSwap(jobPending, jobRunning) {
+		job.cancel()
+		return true
+	}
+
+	start := wp.clock.Now()
+	wait := start.Sub(job.added)
+	wp.waitTime.observe(wait)
+	wp.running.Add(1)
+	if wp.hooks.OnStart != nil {
+		wp.hooks.OnStart(wait)
+	}
+
+	var err error
+	retrying := false
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := &PanicError{Value: value, Stack: debug.Stack()}
+			wp.panics.Add(1)
+			if job.onPanic != nil {
+				job.onPanic(panicErr)
+			}
+
+			wp.report(panicErr)
+			err = panicErr
+		} else if !retrying {
+			wp.completed.Add(1)
+		}
+
+		run := wp.clock.Now().Sub(start)
+		wp.runTime.observe(run)
+		wp.running.Add(-1)
+		if wp.hooks.OnFinish != nil {
+			wp.hooks.OnFinish(run, err)
+		}
+
+		if !retrying {
+			job.cancel()
+		}
+	}()
+
+	job.attempt++
+	if err = job.run(job.ctx); err != nil {
+		if retrying = wp.retryLater(job, err); !retrying {
+			wp.fail(job, err)
+		}
+	}
+
+	return true
+}
+
+// retryLater schedules the next attempt of the failed task by the retry policy
+func (wp *WorkerPool) retryLater(job *job, err error) bool {
+	var permanent *permanentError
+	if wp.retry == nil || job.attempt >= wp.retry.MaxAttempts || errors.As(err, &permanent) || job.ctx.Err() != nil {
+		return false
+	}
+
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+
+	if wp.stopped {
+		return false
+	}
+
+	wp.retrying++
+	// Shutdown waits for backoffs as for workers
+	wp.workers.Add(1)
+	go wp.backoff(job, err)
+	return true
+}
+
+// backoff adds the job to the queue again after the delay,
+// the job goes to the dead-letter queue if its context is done
+func (wp *WorkerPool) backoff(job *job, err error) {
+	defer wp.workers.Done()
+
+	for {
+		timer := wp.clock.NewTimer(wp.retry.delay(job.attempt))
+		select {
+		case <-timer.C():
+		case <-job.ctx.Done():
+			timer.Stop()
+			wp.stopRetrying()
+			wp.fail(job, err)
+			job.cancel()
+			return
+		}
+
+		wp.mutex.Lock()
+		if wp.stopped {
+			wp.mutex.Unlock()
+			wp.stopRetrying()
+			wp.fail(job, err)
+			job.cancel()
+			return
+		}
+
+		job.added = wp.clock.Now()
+		job.state.Store(jobPending)
+		if wp.send(job) {
+			wp.retrying--
+			if wp.closed {
+				wp.closeTasks()
+			}
+
+			wp.mutex.Unlock()
+			return
+		}
+
+		// the queue is full, one more backoff
+		job.state.Store(jobRunning)
+		wp.mutex.Unlock()
+	}
+}
+
+func (wp *WorkerPool) stopRetrying() {
+	wp.mutex.Lock()
+	defer wp.mutex.Unlock()
+
+	wp.retrying--
+	if wp.closed {
+		wp.closeTasks()
+	}
+}
+
+// fail finishes the failed task, it goes to the
+// dead-letter queue if the retry policy is set
+func (wp *WorkerPool) fail(job *job, err error) {
+	if wp.retry != nil {
+		wp.mutex.Lock()
+		wp.deadLetters = append(wp.deadLetters, DeadLetter{Task: job.run, Err: err, Attempts: job.attempt})
+		wp.mutex.Unlock()
+	}
+
+	wp.failures.Add(1)
+	wp.report(err)
+}
+
+func (wp *WorkerPool) report(err error) {
+	if wp.onError != nil {
+		wp.onError(err)
+	}
+}
+
+// wait returns the next job, false means that the
+// pool is closed or the worker has been idle too long
+func (wp *WorkerPool) wait() (*job, bool) {
+	for {
+		var timer Timer
+		var timeout <-chan time.Time
+		if wp.idleTimeout > 0 {
+			timer = wp.clock.NewTimer(wp.idleTimeout)
+			timeout = timer.C()
+		}
+
+		select {
+		case job, ok := <-wp.tasks:
+			if timer != nil {
+				timer.Stop()
+			}
+
+			wp.mutex.Lock()
+			if ok {
+				wp.received++
+				if wp.stopped {
+					wp.handedBack = append(wp.handedBack, job)
+					wp.handOff.Broadcast()
+					wp.mutex.Unlock()
+					continue
+				}
+			}
+
+			wp.idleWorkers--
+			if !ok {
+				wp.workersCount--
+			}
+			wp.mutex.Unlock()
+			return job, ok
+		case <-timeout:
+			wp.mutex.Lock()
+			// push counts the worker as idle, so it
+			// must not leave queued tasks without workers
+			if wp.workersCount > wp.minWorkers && len(wp.tasks) == 0 {
+				wp.workersCount--
+				wp.idleWorkers--
+				wp.scale(ScaleDown)
+				wp.mutex.Unlock()
+				return nil, false
+			}
+			wp.mutex.Unlock()
+		}
+	}
+}
+
+// Future is a result of a task submitted to the pool
+type Future[T any] struct {
+	job   *job
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Submit never blocks, a future of a rejected
+// task is completed with the error of AddTask
+func Submit[T any](pool *WorkerPool, task func(ctx context.Context) (T, error)) *Future[T] {
+	future := &Future[T]{done: make(chan struct{})}
+	// errors of futures are returned by Get,
+	// only panics are reported by the pool
+	future.job = pool.newJob(func(ctx context.Context) error {
+		value, err := task(ctx)
+		future.complete(value, err)
+		return nil
+	})
+
+	future.job.onPanic = func(err *PanicError) {
+		future.complete(*new(T), err)
+	}
+
+	future.job.onDrop = func() {
+		future.complete(*new(T), ErrPoolClosed)
+	}
+
+	if err := pool.enqueue(future.job); err != nil {
+		// a rejected job is never run, so Cancel must not complete it again
+		future.job.state.Store(jobCancelled)
+		future.complete(*new(T), err)
+	}
+
+	return future
+}
+
+func (f *Future[T]) complete(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
+
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits for the result of the task or the end of the context
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return *new(T), ctx.Err()
+	}
+}
+
+// Cancel prevents the task from running if it is not started
+// yet, otherwise it cancels the context of the running task
+func (f *Future[T]) Cancel() {
+	if f.job.state.CompareAndSwap(jobPending, jobCancelled) {
+		f.complete(*new(T), context.Canceled)
+	}
+
+	f.job.cancel()
+}
+
+// AwaitAll waits for all futures and returns
+// their values with the first error by order
+func AwaitAll[T any](ctx context.Context, futures ...*Future[T]) ([]T, error) {
+	values := make([]T, len(futures))
+	var firstErr error
+	for idx, future := range futures {
+		value, err := future.Get(ctx)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		values[idx] = value
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return values, firstErr
+}
+
+// AwaitAny waits for the first completed future
+// and returns its index with the result
+func AwaitAny[T any](ctx context.Context, futures ...*Future[T]) (int, T, error) {
+	cases := make([]reflect.SelectCase, 0, len(futures)+1)
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	for _, future := range futures {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(future.done)})
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	if chosen == 0 {
+		return -1, *new(T), ctx.Err()
+	}
+
+	future := futures[chosen-1]
+	return chosen - 1, future.value, future.err
 }
 
 func TestWorkerPool(t *testing.T) {
@@ -52,7 +1024,744 @@ func TestWorkerPool(t *testing.T) {
 	_ = pool.AddTask(task)
 	_ = pool.AddTask(task)
 	_ = pool.AddTask(task)
-	pool.Shutdown() // wait tasks
+	pool.Shutdown(context.Background()) // wait tasks
 
 	assert.Equal(t, int32(6), counter.Load())
 }
+
+func TestWorkerPoolWithFullQueue(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool := NewWorkerPool(1, WithQueueSize(1))
+
+	assert.NoError(t, pool.AddTask(func() {
+		close(started)
+		<-release
+	}))
+
+	<-started
+	assert.NoError(t, pool.AddTask(func() {}))
+	assert.ErrorIs(t, pool.AddTask(func() {}), ErrPoolFull)
+
+	close(release)
+	pool.Shutdown(context.Background())
+}
+
+func TestWorkerPoolAfterShutdown(t *testing.T) {
+	var counter atomic.Int32
+	pool := NewWorkerPool(1, WithQueueSize(10))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, pool.AddTask(func() {
+			time.Sleep(time.Millisecond * 10)
+			counter.Add(1)
+		}))
+	}
+
+	assert.NoError(t, pool.Shutdown(context.Background())) // queued tasks are drained
+	assert.Equal(t, int32(10), counter.Load())
+	assert.ErrorIs(t, pool.AddTask(func() {}), ErrPoolClosed)
+	pool.Shutdown(context.Background())
+	assert.ErrorIs(t, pool.ctx.Err(), context.Canceled)
+}
+
+func TestWorkerPoolRejectedJobsAreCancelled(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	pool := NewWorkerPool(1, WithQueueSize(1))
+
+	assert.NoError(t, pool.AddTask(func() {
+		close(started)
+		<-release
+	}))
+
+	<-started
+	assert.NoError(t, pool.AddTask(func() {}))
+
+	full := pool.newJob(func(context.Context) error { return nil })
+	assert.ErrorIs(t, pool.enqueue(full), ErrPoolFull)
+	assert.ErrorIs(t, full.ctx.Err(), context.Canceled)
+
+	close(release)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+}
+
+func TestFutures(t *testing.T) {
+	pool := NewWorkerPool(2)
+	defer pool.Shutdown(context.Background())
+
+	futures := make([]*Future[int], 0, 5)
+	for i := 0; i < 5; i++ {
+		futures = append(futures, Submit(pool, func(ctx context.Context) (int, error) {
+			time.Sleep(time.Millisecond * time.Duration(10*(5-i)))
+			return i * i, nil
+		}))
+	}
+
+	values, err := AwaitAll(context.Background(), futures...)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 4, 9, 16}, values)
+
+	failed := Submit(pool, func(ctx context.Context) (int, error) {
+		return 0, errors.New("error")
+	})
+
+	_, err = failed.Get(context.Background())
+	assert.EqualError(t, err, "error")
+}
+
+func TestFutureCancellation(t *testing.T) {
+	pool := NewWorkerPool(1)
+	defer pool.Shutdown(context.Background())
+
+	started := make(chan struct{})
+	running := Submit(pool, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+
+	var executed atomic.Bool
+	pending := Submit(pool, func(ctx context.Context) (int, error) {
+		executed.Store(true)
+		return 1, nil
+	})
+
+	<-started
+	pending.Cancel()
+	_, err := pending.Get(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	running.Cancel()
+	_, err = running.Get(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+
+	next := Submit(pool, func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+
+	index, value, err := AwaitAny(context.Background(), next)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, 2, value)
+	assert.False(t, executed.Load())
+}
+
+func TestFutureCancellationAfterRejection(t *testing.T) {
+	pool := NewWorkerPool(1)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+
+	future := Submit(pool, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+
+	assert.NotPanics(t, future.Cancel)
+	_, err := future.Get(context.Background())
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestAwaitAny(t *testing.T) {
+	pool := NewWorkerPool(2)
+	defer pool.Shutdown(context.Background())
+
+	slow := Submit(pool, func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+		return "slow", nil
+	})
+
+	fast := Submit(pool, func(ctx context.Context) (string, error) {
+		return "fast", nil
+	})
+
+	index, value, err := AwaitAny(context.Background(), slow, fast)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, "fast", value)
+	slow.Cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	waiting := Submit(pool, func(ctx context.Context) (string, error) {
+		<-blocked
+		return "", nil
+	})
+
+	_, _, err = AwaitAny(ctx, waiting)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	deadline time.Time
+	channel  chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.channel
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	_, found := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	return found
+}
+
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:    time.Unix(0, 0),
+		timers: make(map[*fakeTimer]struct{}),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(duration time.Duration) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	timer := &fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(duration),
+		channel:  make(chan time.Time, 1),
+	}
+
+	c.timers[timer] = struct{}{}
+	return timer
+}
+
+func (c *fakeClock) Advance(duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(duration)
+	for timer := range c.timers {
+		if !timer.deadline.After(c.now) {
+			timer.channel <- c.now
+			delete(c.timers, timer)
+		}
+	}
+}
+
+func (c *fakeClock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.timers)
+}
+
+func TestAutoscalingWorkerPoolWithoutMinWorkers(t *testing.T) {
+	var counter atomic.Int32
+	pool := NewAutoscalingWorkerPool(0, 2, time.Microsecond)
+	for i := 0; i < 300; i++ {
+		assert.NoError(t, pool.AddTask(func() {
+			counter.Add(1)
+		}))
+
+		// workers are stopped by the idle timeout between tasks
+		time.Sleep(time.Microsecond * time.Duration(i%7))
+	}
+
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, int32(300), counter.Load())
+}
+
+func TestAutoscalingWorkerPool(t *testing.T) {
+	clock := newFakeClock()
+	var events []ScaleEvent
+	pool := NewAutoscalingWorkerPool(1, 3, time.Minute, WithClock(clock), WithScaleHandler(func(event ScaleEvent) {
+		events = append(events, event)
+	}))
+
+	assert.Equal(t, 1, pool.Workers())
+
+	release := make(chan struct{})
+	var counter atomic.Int32
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, pool.AddTask(func() {
+			<-release
+			counter.Add(1)
+		}))
+	}
+
+	assert.Equal(t, 3, pool.Workers())
+	close(release)
+
+	// all workers are idle and wait for their timers
+	assert.Eventually(t, func() bool {
+		return counter.Load() == 5 && clock.Timers() == 3
+	}, time.Second, time.Millisecond)
+
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool {
+		return pool.Workers() == 1
+	}, time.Second, time.Millisecond)
+
+	pool.Shutdown(context.Background())
+	assert.Equal(t, 0, pool.Workers())
+
+	directions := make([]ScaleDirection, 0, len(events))
+	for _, event := range events {
+		directions = append(directions, event.Direction)
+	}
+
+	assert.Equal(t, []ScaleDirection{ScaleUp, ScaleUp, ScaleDown, ScaleDown}, directions)
+	assert.Equal(t, 1, events[len(events)-1].Workers)
+	assert.Equal(t, time.Unix(60, 0), events[len(events)-1].Time)
+}
+
+func TestWorkerPoolWithPanics(t *testing.T) {
+	var mutex sync.Mutex
+	var errs []error
+	pool := NewWorkerPool(2, WithErrorHandler(func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		errs = append(errs, err)
+	}))
+
+	assert.NoError(t, pool.AddTask(func() {
+		panic("task panic")
+	}))
+
+	assert.NoError(t, pool.AddTaskContext(func(ctx context.Context) error {
+		return errors.New("task error")
+	}))
+
+	future := Submit(pool, func(ctx context.Context) (int, error) {
+		var values map[string]int
+		values["key"] = 1 // nil map
+		return 0, nil
+	})
+
+	var panicErr *PanicError
+	_, err := future.Get(context.Background())
+	assert.ErrorAs(t, err, &panicErr)
+
+	var counter atomic.Int32
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, pool.AddTask(func() {
+			counter.Add(1)
+		}))
+	}
+
+	assert.Eventually(t, func() bool {
+		return counter.Load() == 10
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, 2, pool.Workers())
+	pool.Shutdown(context.Background())
+
+	assert.Equal(t, int64(2), pool.Panics())
+	assert.Equal(t, int64(1), pool.Failures())
+	assert.Len(t, errs, 3)
+	for _, err := range errs {
+		if errors.As(err, &panicErr) {
+			assert.Contains(t, string(panicErr.Stack), "panic")
+		} else {
+			assert.EqualError(t, err, "task error")
+		}
+	}
+}
+
+func TestWorkerPoolShutdownWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewWorkerPool(1)
+	assert.NoError(t, pool.AddTask(func() {
+		<-release
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, pool.AddTask(func() {}), ErrPoolClosed)
+
+	close(release)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+}
+
+func TestWorkerPoolShutdownNow(t *testing.T) {
+	started := make(chan struct{})
+	runningErr := make(chan error, 1)
+	pool := NewWorkerPool(1)
+	assert.NoError(t, pool.AddTaskContext(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		runningErr <- ctx.Err()
+		return nil
+	}))
+
+	<-started
+
+	var counter atomic.Int32
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, pool.AddTask(func() {
+			counter.Add(1)
+		}))
+	}
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, pool.AddKeyedTask("key", func() {
+			counter.Add(1)
+		}))
+	}
+
+	future := Submit(pool, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+
+	tasks := pool.ShutdownNow()
+	assert.Len(t, tasks, 5)
+	assert.ErrorIs(t, <-runningErr, context.Canceled)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+
+	_, err := future.Get(context.Background())
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.ErrorIs(t, pool.AddTask(func() {}), ErrPoolClosed)
+	assert.Empty(t, pool.ShutdownNow())
+
+	// tasks can be re-enqueued to another pool
+	next := NewWorkerPool(1)
+	for _, task := range tasks {
+		assert.NoError(t, next.AddTaskContext(task))
+	}
+
+	assert.NoError(t, next.Shutdown(context.Background()))
+	assert.Equal(t, int32(5), counter.Load())
+}
+
+func TestWorkerPoolWithKeyedTasks(t *testing.T) {
+	var mutex sync.Mutex
+	order := make(map[string][]int)
+	running := make(map[string]*atomic.Int32)
+	keys := []string{"user-1", "user-2", "user-3"}
+	for _, key := range keys {
+		running[key] = &atomic.Int32{}
+	}
+
+	pool := NewWorkerPool(4)
+	for i := 0; i < 20; i++ {
+		for _, key := range keys {
+			assert.NoError(t, pool.AddKeyedTask(key, func() {
+				assert.Equal(t, int32(1), running[key].Add(1))
+				time.Sleep(time.Millisecond)
+				running[key].Add(-1)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				order[key] = append(order[key], i)
+			}))
+		}
+	}
+
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	for _, key := range keys {
+		assert.IsIncreasing(t, order[key])
+		assert.Len(t, order[key], 20)
+	}
+}
+
+func TestWorkerPoolKeyedTasksFairness(t *testing.T) {
+	var mutex sync.Mutex
+	var order []string
+	record := func(name string) func() {
+		return func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, name)
+		}
+	}
+
+	release := make(chan struct{})
+	pool := NewWorkerPool(1, WithQueueSize(10))
+	assert.NoError(t, pool.AddTask(func() {
+		<-release
+	}))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, pool.AddKeyedTask("hot", record(fmt.Sprintf("hot-%d", i))))
+	}
+
+	assert.NoError(t, pool.AddTask(record("task-1")))
+	assert.NoError(t, pool.AddKeyedTask("cold", record("cold-0")))
+	assert.NoError(t, pool.AddTask(record("task-2")))
+
+	close(release)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, []string{"hot-0", "task-1", "cold-0", "task-2", "hot-1", "hot-2"}, order)
+}
+
+func TestWorkerPoolKeyedTasksWithFullQueue(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	pool := NewWorkerPool(1, WithQueueSize(2))
+	assert.NoError(t, pool.AddTask(func() {
+		close(started)
+		<-release
+	}))
+
+	<-started
+
+	var counter atomic.Int32
+	task := func() {
+		counter.Add(1)
+	}
+
+	assert.NoError(t, pool.AddKeyedTask("key", task))
+	assert.NoError(t, pool.AddKeyedTask("key", task))                // waits for its turn
+	assert.ErrorIs(t, pool.AddKeyedTask("key", task), ErrPoolFull)   // no place in the queue
+	assert.ErrorIs(t, pool.AddKeyedTask("other", task), ErrPoolFull) // the place is reserved
+	assert.ErrorIs(t, pool.AddTask(task), ErrPoolFull)
+
+	close(release)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, int32(2), counter.Load())
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Second * 10}
+	assert.Equal(t, time.Second, policy.delay(1))
+	assert.Equal(t, time.Second*2, policy.delay(2))
+	assert.Equal(t, time.Second*8, policy.delay(4))
+	assert.Equal(t, time.Second*10, policy.delay(5))
+	assert.Equal(t, time.Second*10, policy.delay(100))
+
+	policy = RetryPolicy{BaseDelay: time.Second}
+	assert.Equal(t, time.Duration(math.MaxInt64), policy.delay(100))
+
+	policy = RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(2)
+		assert.True(t, delay > time.Second && delay <= time.Second*2)
+	}
+}
+
+func TestWorkerPoolWithRetries(t *testing.T) {
+	clock := newFakeClock()
+	var errs []error
+	pool := NewWorkerPool(1, WithClock(clock), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+	}))
+
+	var attempts atomic.Int32
+	var healthy atomic.Bool
+	assert.NoError(t, pool.AddTaskContext(func(ctx context.Context) error {
+		attempts.Add(1)
+		if healthy.Load() {
+			return nil
+		}
+
+		return errors.New("temporary error")
+	}))
+
+	for attempt, delay := range []time.Duration{time.Second, time.Second * 2} {
+		assert.Eventually(t, func() bool {
+			return clock.Timers() == 1
+		}, time.Second, time.Millisecond)
+
+		assert.Equal(t, int32(attempt+1), attempts.Load())
+		clock.Advance(delay - time.Millisecond)
+		assert.Equal(t, 1, clock.Timers()) // backoff is not over yet
+		clock.Advance(time.Millisecond)
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(pool.DeadLetters()) == 1
+	}, time.Second, time.Millisecond)
+
+	deadLetter := pool.DeadLetters()[0]
+	assert.Equal(t, 3, deadLetter.Attempts)
+	assert.EqualError(t, deadLetter.Err, "temporary error")
+	assert.Equal(t, int32(3), attempts.Load())
+
+	// permanent errors are not retried
+	assert.NoError(t, pool.AddTaskContext(func(ctx context.Context) error {
+		return Permanent(fmt.Errorf("incorrect task: %w", ErrPoolFull))
+	}))
+
+	assert.Eventually(t, func() bool {
+		return len(pool.DeadLetters()) == 2
+	}, time.Second, time.Millisecond)
+
+	deadLetter = pool.DeadLetters()[1]
+	assert.Equal(t, 1, deadLetter.Attempts)
+	assert.ErrorIs(t, deadLetter.Err, ErrPoolFull)
+
+	healthy.Store(true)
+	replayed, err := pool.ReplayDeadLetters()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, int32(4), attempts.Load())
+	assert.Len(t, pool.DeadLetters(), 1) // the permanent one failed again
+	assert.Equal(t, int64(3), pool.Failures())
+	assert.Len(t, errs, 3)
+}
+
+func TestWorkerPoolRetriesDontBlockWorkers(t *testing.T) {
+	clock := newFakeClock()
+	pool := NewWorkerPool(1, WithClock(clock), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Minute,
+	}))
+
+	var attempts atomic.Int32
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, pool.AddTaskContext(func(ctx context.Context) error {
+			if attempts.Add(1) > 3 {
+				return nil
+			}
+
+			return errors.New("temporary error")
+		}))
+	}
+
+	assert.Eventually(t, func() bool {
+		return clock.Timers() == 3
+	}, time.Second, time.Millisecond)
+
+	// the only worker is free during backoffs
+	var counter atomic.Int32
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, pool.AddTask(func() {
+			counter.Add(1)
+		}))
+	}
+
+	assert.Eventually(t, func() bool {
+		return counter.Load() == 10
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, 3, pool.Stats().Retrying)
+
+	// shutdown waits for scheduled retries
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+
+	clock.Advance(time.Minute)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, int32(6), attempts.Load())
+	assert.Equal(t, 0, pool.Stats().Retrying)
+	assert.Empty(t, pool.DeadLetters())
+}
+
+func TestWorkerPoolRetriesWithCancellation(t *testing.T) {
+	clock := newFakeClock()
+	pool := NewWorkerPool(1, WithClock(clock), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Minute,
+	}))
+
+	assert.NoError(t, pool.AddTaskContext(func(ctx context.Context) error {
+		return errors.New("temporary error")
+	}))
+
+	assert.Eventually(t, func() bool {
+		return clock.Timers() == 1
+	}, time.Second, time.Millisecond)
+
+	// a started task is not returned, but it goes to the dead-letter queue
+	assert.Empty(t, pool.ShutdownNow())
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, 0, clock.Timers())
+
+	deadLetters := pool.DeadLetters()
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, 1, deadLetters[0].Attempts)
+
+	replayed, err := pool.ReplayDeadLetters()
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.Equal(t, 0, replayed)
+	assert.Len(t, pool.DeadLetters(), 1)
+}
+
+func TestWorkerPoolStats(t *testing.T) {
+	var mutex sync.Mutex
+	var enqueued int
+	var waits, runs []time.Duration
+	var errs []error
+	hooks := Hooks{
+		OnEnqueue: func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			enqueued++
+		},
+		OnStart: func(wait time.Duration) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			waits = append(waits, wait)
+		},
+		OnFinish: func(run time.Duration, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			runs = append(runs, run)
+			errs = append(errs, err)
+		},
+	}
+
+	clock := newFakeClock()
+	pool := NewWorkerPool(1, WithQueueSize(1), WithClock(clock), WithHooks(hooks))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.NoError(t, pool.AddTask(func() {
+		close(started)
+		<-release
+	}))
+
+	<-started
+	assert.NoError(t, pool.AddTask(func() {
+		panic("task panic")
+	}))
+
+	assert.ErrorIs(t, pool.AddTask(func() {}), ErrPoolFull)
+
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.Queued)
+	assert.Equal(t, int64(1), stats.Running)
+	assert.Equal(t, int64(1), stats.Rejected)
+
+	clock.Advance(time.Millisecond * 20)
+	close(release)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+
+	stats = pool.Stats()
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, int64(0), stats.Running)
+	assert.Equal(t, int64(1), stats.Completed)
+	assert.Equal(t, int64(1), stats.Panicked)
+	assert.Equal(t, histogramBounds[:], stats.WaitTime.Bounds)
+	assert.Equal(t, []int64{1, 0, 0, 1, 0, 0, 0}, stats.WaitTime.Counts)
+	assert.Equal(t, []int64{1, 0, 0, 1, 0, 0, 0}, stats.RunTime.Counts)
+
+	assert.Equal(t, 2, enqueued)
+	assert.Equal(t, []time.Duration{0, time.Millisecond * 20}, waits)
+	assert.Equal(t, []time.Duration{time.Millisecond * 20, 0}, runs)
+	assert.NoError(t, errs[0])
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, errs[1], &panicErr)
+}
@@ -3,6 +3,13 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -10,22 +17,312 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+const defaultGroupName = "group"
+
+type Option func(*Group)
+
+// WithName sets the name of the group for errors of children and Dump
+func WithName(name string) Option {
+	return func(group *Group) {
+		group.name = name
+	}
+}
+
+// WithCollectAll makes errors not to cancel the context, all
+// goroutines run to completion and Wait returns joined errors
+func WithCollectAll() Option {
+	return func(group *Group) {
+		group.collectAll = true
+	}
+}
+
+// PanicError keeps the value and the stack of a panicked goroutine
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("goroutine panicked: %v\n%s", e.Value, e.Stack)
+}
+
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Group runs goroutines with a common context which
+// is cancelled after the first error of a goroutine
 type Group struct {
-	// need to implement
+	name       string
+	wg         sync.WaitGroup
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+	mutex      sync.Mutex
+	errs       []error // only the first one if errors are not collected
+	panicErr   *PanicError
+	collectAll bool
+	limit      chan struct{} // semaphore of running goroutines
+
+	parent   *Group
+	children []*Group
+	attached bool              // the group is in children of the parent, guarded by its mutex
+	pending  map[uint64]string // call sites of running goroutines
+	nextID   uint64
 }
 
-func NewErrGroup(ctx context.Context) (*Group, context.Context) {
-	// need to implement
-	return &Group{}, ctx
+func NewErrGroup(ctx context.Context, options ...Option) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	group := &Group{
+		name:   defaultGroupName,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, option := range options {
+		option(group)
+	}
+
+	return group, ctx
 }
 
+// Child creates a nested group with the context derived from the context of
+// the group, so cancellation of the group cancels the whole tree. Wait of the
+// group also waits for all its children, errors and panics of a child are passed
+// to the group at once. A child is detached from the group when its Wait
+// returns and attached again by a new goroutine, so a long-lived group
+// keeps only live scopes.
+func (g *Group) Child(name string, options ...Option) (*Group, context.Context) {
+	ctx := g.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	child, childCtx := NewErrGroup(ctx, append([]Option{WithName(name)}, options...)...)
+	child.parent = g
+	g.attach(child)
+
+	return child, childCtx
+}
+
+func (g *Group) attach(child *Group) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if !child.attached {
+		child.attached = true
+		g.children = append(g.children, child)
+	}
+}
+
+func (g *Group) detach(child *Group) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if idx := slices.Index(g.children, child); idx != -1 {
+		child.attached = false
+		g.children = slices.Delete(g.children, idx, idx+1)
+	}
+}
+
+// SetLimit limits number of running goroutines, a negative
+// value removes the limit, the limit can't be changed while
+// goroutines of the group are running
+func (g *Group) SetLimit(limit int) {
+	if len(g.limit) != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %d goroutines in the group are still active", len(g.limit)))
+	}
+
+	if limit < 0 {
+		g.limit = nil
+		return
+	}
+
+	g.limit = make(chan struct{}, limit)
+}
+
+// Go blocks until the goroutine can be started within the limit
 func (g *Group) Go(action func() error) {
-	// need to implement
+	if g.limit != nil {
+		g.limit <- struct{}{}
+	}
+
+	g.start(action)
+}
+
+// TryGo starts the goroutine only if it doesn't exceed the limit
+func (g *Group) TryGo(action func() error) bool {
+	if g.limit != nil {
+		select {
+		case g.limit <- struct{}{}:
+		default:
+			return false
+		}
+	}
+
+	g.start(action)
+	return true
 }
 
+func (g *Group) start(action func() error) {
+	// call site of Go or TryGo
+	_, file, line, _ := runtime.Caller(2)
+	if g.parent != nil {
+		g.parent.attach(g)
+	}
+
+	g.mutex.Lock()
+	if g.pending == nil {
+		g.pending = make(map[uint64]string)
+	}
+
+	g.nextID++
+	id := g.nextID
+	g.pending[id] = fmt.Sprintf("%s:%d", file, line)
+	g.mutex.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.done(id)
+		defer func() {
+			if value := recover(); value != nil {
+				g.recovered(&PanicError{Value: value, Stack: debug.Stack()})
+			}
+		}()
+
+		if err := action(); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *Group) fail(err error) {
+	g.mutex.Lock()
+	first := len(g.errs) == 0
+	if g.collectAll {
+		g.errs = append(g.errs, err)
+	} else if first {
+		g.errs = append(g.errs, err)
+		g.cancelWith(err)
+	}
+	g.mutex.Unlock()
+
+	if g.parent != nil && (g.collectAll || first) {
+		g.parent.fail(fmt.Errorf("%s: %w", g.name, err))
+	}
+}
+
+// recovered keeps the first panic to be raised
+// again by Wait, a panic always cancels the context
+func (g *Group) recovered(err *PanicError) {
+	g.mutex.Lock()
+	first := g.panicErr == nil
+	if first {
+		g.panicErr = err
+		g.cancelWith(err)
+	}
+	g.mutex.Unlock()
+
+	if g.parent != nil && first {
+		g.parent.recovered(err)
+	}
+}
+
+func (g *Group) cancelWith(cause error) {
+	if g.cancel != nil {
+		g.cancel(cause)
+	}
+}
+
+func (g *Group) done(id uint64) {
+	if g.limit != nil {
+		<-g.limit
+	}
+
+	g.mutex.Lock()
+	delete(g.pending, id)
+	g.mutex.Unlock()
+
+	g.wg.Done()
+}
+
+// Wait waits for all goroutines and returns the first error or
+// all joined errors, a panic of a goroutine is raised again
+// in the waiting goroutine as *PanicError with the original value
 func (g *Group) Wait() error {
-	// need to implement
-	return nil
+	g.waitTree()
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.panicErr != nil {
+		panic(g.panicErr)
+	}
+
+	err := errors.Join(g.errs...)
+	if !g.collectAll && err != nil {
+		err = g.errs[0]
+	}
+
+	g.cancelWith(err)
+	return err
+}
+
+// waitTree waits for goroutines of the group and then for its children,
+// children are created only by running goroutines or before Wait.
+// The finished group is detached from its parent.
+func (g *Group) waitTree() {
+	g.wg.Wait()
+
+	g.mutex.Lock()
+	children := slices.Clone(g.children)
+	g.mutex.Unlock()
+
+	for _, child := range children {
+		child.waitTree()
+	}
+
+	if g.parent != nil {
+		g.parent.detach(g)
+	}
+}
+
+// Dump renders the tree of live groups with call
+// sites of pending goroutines to find hung scopes:
+//
+//	server: 1 pending
+//	  goroutine 1 started at main.go:20
+//	  db: 0 pending
+func (g *Group) Dump() string {
+	var builder strings.Builder
+	g.dump(&builder, 0)
+	return builder.String()
+}
+
+func (g *Group) dump(builder *strings.Builder, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	g.mutex.Lock()
+	ids := make([]uint64, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, id)
+	}
+
+	slices.Sort(ids)
+	fmt.Fprintf(builder, "%s%s: %d pending\n", indent, g.name, len(ids))
+	for _, id := range ids {
+		fmt.Fprintf(builder, "%s  goroutine %d started at %s\n", indent, id, g.pending[id])
+	}
+
+	children := slices.Clone(g.children)
+	g.mutex.Unlock()
+
+	for _, child := range children {
+		child.dump(builder, depth+1)
+	}
 }
 
 func TestErrGroupWithoutError(t *testing.T) {
@@ -72,3 +369,223 @@ func TestErrGroupWithError(t *testing.T) {
 	assert.Equal(t, int32(0), counter.Load())
 	assert.Error(t, err)
 }
+
+func TestErrGroupWithLimit(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	group, _ := NewErrGroup(context.Background())
+	group.SetLimit(2)
+
+	for i := 0; i < 10; i++ {
+		group.Go(func() error {
+			current := running.Add(1)
+			defer running.Add(-1)
+
+			for {
+				previous := maxRunning.Load()
+				if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond * 10)
+			return nil
+		})
+	}
+
+	assert.NoError(t, group.Wait())
+	assert.Equal(t, int32(2), maxRunning.Load())
+}
+
+func TestErrGroupTryGo(t *testing.T) {
+	group, ctx := NewErrGroup(context.Background())
+	group.SetLimit(1)
+
+	release := make(chan struct{})
+	assert.True(t, group.TryGo(func() error {
+		<-release
+		return errors.New("error")
+	}))
+
+	assert.False(t, group.TryGo(func() error {
+		return nil
+	}))
+
+	assert.Panics(t, func() {
+		group.SetLimit(2)
+	})
+
+	close(release)
+	assert.EqualError(t, group.Wait(), "error")
+	assert.EqualError(t, context.Cause(ctx), "error")
+
+	assert.True(t, group.TryGo(func() error {
+		return nil
+	}))
+
+	assert.EqualError(t, group.Wait(), "error")
+}
+
+func TestErrGroupWithCollectAll(t *testing.T) {
+	var counter atomic.Int32
+	group, ctx := NewErrGroup(context.Background(), WithCollectAll())
+
+	errNotFound := errors.New("not found")
+	for i := 0; i < 5; i++ {
+		group.Go(func() error {
+			time.Sleep(time.Millisecond * 10)
+			counter.Add(1)
+			if i%2 == 0 {
+				return fmt.Errorf("task %d: %w", i, errNotFound)
+			}
+
+			return nil
+		})
+	}
+
+	group.Go(func() error {
+		return &fs.PathError{Op: "open", Path: "config", Err: fs.ErrPermission}
+	})
+
+	err := group.Wait()
+	assert.Equal(t, int32(5), counter.Load())
+	assert.ErrorIs(t, err, errNotFound)
+
+	var pathErr *fs.PathError
+	assert.ErrorAs(t, err, &pathErr)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 4)
+	assert.ErrorIs(t, context.Cause(ctx), err)
+}
+
+func TestErrGroupWithPanic(t *testing.T) {
+	group, ctx := NewErrGroup(context.Background())
+	group.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	errPanic := errors.New("panic")
+	group.Go(func() error {
+		panic(errPanic)
+	})
+
+	defer func() {
+		value := recover()
+		panicErr, ok := value.(*PanicError)
+		if assert.True(t, ok) {
+			assert.Equal(t, errPanic, panicErr.Value)
+			assert.ErrorIs(t, panicErr, errPanic)
+			assert.Contains(t, string(panicErr.Stack), "TestErrGroupWithPanic")
+		}
+	}()
+
+	_ = group.Wait()
+	t.Fatal("wait must panic")
+}
+
+func TestErrGroupWithChildren(t *testing.T) {
+	group, _ := NewErrGroup(context.Background(), WithName("server"))
+	db, _ := group.Child("db")
+	cache, _ := group.Child("cache")
+
+	var finished atomic.Bool
+	release := make(chan struct{})
+	db.Go(func() error {
+		<-release
+		time.Sleep(time.Millisecond * 10)
+		finished.Store(true)
+		return nil
+	})
+
+	group.Go(func() error {
+		<-release
+		return nil
+	})
+
+	dump := group.Dump()
+	assert.Contains(t, dump, "server: 1 pending\n  goroutine 1 started at ")
+	assert.Contains(t, dump, "  db: 1 pending\n    goroutine 1 started at ")
+	assert.Contains(t, dump, "homework_test.go:")
+	assert.Contains(t, dump, "  cache: 0 pending\n")
+
+	close(release)
+	assert.NoError(t, group.Wait())
+	assert.True(t, finished.Load())
+	// finished scopes are detached
+	assert.Equal(t, "server: 0 pending\n", group.Dump())
+	assert.Empty(t, group.children)
+	assert.NoError(t, cache.Wait())
+}
+
+func TestErrGroupChildPerRequest(t *testing.T) {
+	group, _ := NewErrGroup(context.Background(), WithName("server"))
+	for i := 0; i < 100; i++ {
+		request, _ := group.Child("request")
+		request.Go(func() error {
+			return nil
+		})
+
+		assert.NoError(t, request.Wait())
+	}
+
+	assert.Empty(t, group.children)
+	assert.Equal(t, "server: 0 pending\n", group.Dump())
+
+	// a reused child is attached again and waited by the group
+	request, _ := group.Child("request")
+	assert.NoError(t, request.Wait())
+
+	var finished atomic.Bool
+	release := make(chan struct{})
+	request.Go(func() error {
+		<-release
+		finished.Store(true)
+		return nil
+	})
+
+	assert.Contains(t, group.Dump(), "  request: 1 pending\n")
+	close(release)
+	assert.NoError(t, group.Wait())
+	assert.True(t, finished.Load())
+}
+
+func TestErrGroupChildrenCancellation(t *testing.T) {
+	parentCtx, cancel := context.WithCancel(context.Background())
+	group, _ := NewErrGroup(parentCtx, WithName("server"))
+	db, _ := group.Child("db")
+	replica, replicaCtx := db.Child("replica")
+
+	replica.Go(func() error {
+		<-replicaCtx.Done()
+		return nil
+	})
+
+	cancel()
+	assert.NoError(t, group.Wait())
+	assert.ErrorIs(t, replicaCtx.Err(), context.Canceled)
+
+	// an error of a child cancels the whole tree
+	group, ctx := NewErrGroup(context.Background(), WithName("server"))
+	db, dbCtx := group.Child("db")
+	replica, _ = db.Child("replica")
+
+	group.Go(func() error {
+		<-ctx.Done()
+		return nil
+	})
+
+	db.Go(func() error {
+		<-dbCtx.Done()
+		return nil
+	})
+
+	errTimeout := errors.New("timeout")
+	replica.Go(func() error {
+		return errTimeout
+	})
+
+	err := group.Wait()
+	assert.ErrorIs(t, err, errTimeout)
+	assert.EqualError(t, err, "db: replica: timeout")
+	assert.EqualError(t, db.Wait(), "replica: timeout")
+}
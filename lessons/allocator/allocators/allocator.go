@@ -0,0 +1,43 @@
+package allocators
+
+import (
+	"errors"
+	"unsafe"
+)
+
+var (
+	ErrIncorrectCapacity  = errors.New("incorrect capacity")
+	ErrIncorrectSize      = errors.New("incorrect size")
+	ErrIncorrectAlignment = errors.New("incorrect alignment")
+	ErrIncorrectPointer   = errors.New("incorrect pointer")
+	ErrNotEnoughMemory    = errors.New("not enough memory")
+	ErrOutOfRegion        = errors.New("pointer is outside of the allocator")
+)
+
+// Allocator is the common shape of all allocators,
+// Free releases all blocks at once
+type Allocator interface {
+	Allocate(size int) (unsafe.Pointer, error)
+	Free()
+}
+
+// Deallocator is implemented by allocators which can release
+// a single block, some of them can restrict order of releases
+type Deallocator interface {
+	Allocator
+	Deallocate(pointer unsafe.Pointer) error
+}
+
+// Meter is implemented by allocators which can report
+// how many bytes are reserved including headers and padding
+type Meter interface {
+	InUse() int
+}
+
+func Store[T any](pointer unsafe.Pointer, value T) {
+	*(*T)(pointer) = value
+}
+
+func Load[T any](pointer unsafe.Pointer) T {
+	return *(*T)(pointer)
+}
@@ -0,0 +1,144 @@
+package allocators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// ErrPointerType is returned for types which the garbage collector
+// has to scan, it does not scan memory of the arena
+var ErrPointerType = errors.New("type contains pointers")
+
+var pointerFreeTypes sync.Map // reflect.Type -> error
+
+// Arena is a replacement of the experimental arena package:
+// values are placed into chunks of a linear allocator and
+// released all at once, only types without pointers are allowed
+type Arena struct {
+	allocator *LinearAllocator
+}
+
+func NewArena(capacity int, options ...ChunkOption) (*Arena, error) {
+	allocator, err := NewLinearAllocator(capacity, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Arena{allocator: allocator}, nil
+}
+
+// New returns a pointer to a zero value of T inside the arena
+func New[T any](a *Arena) (*T, error) {
+	var value T
+	pointer, err := allocate(a, reflect.TypeOf(&value).Elem(), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*T)(pointer), nil
+}
+
+// MakeSlice returns a slice inside the arena, append
+// beyond the capacity moves the slice to the heap
+func MakeSlice[T any](a *Arena, length int, capacity int) ([]T, error) {
+	if length < 0 || capacity < length {
+		return nil, ErrIncorrectSize
+	}
+
+	var value T
+	pointer, err := allocate(a, reflect.TypeOf(&value).Elem(), capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	return unsafe.Slice((*T)(pointer), capacity)[:length], nil
+}
+
+// Clone copies a value from the arena to the heap
+func Clone[T any](value *T) *T {
+	cloned := *value
+	return &cloned
+}
+
+// CloneSlice copies a slice from the arena to the heap
+func CloneSlice[T any](slice []T) []T {
+	return append(make([]T, 0, len(slice)), slice...)
+}
+
+// Contains reports whether the pointer points to memory of the arena
+func (a *Arena) Contains(pointer unsafe.Pointer) bool {
+	for _, chunk := range a.allocator.chain.chunks {
+		base := uintptr(unsafe.Pointer(unsafe.SliceData(chunk)))
+		if uintptr(pointer) >= base && uintptr(pointer) < base+uintptr(cap(chunk)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Free clears used memory, so values read after
+// Free are zero instead of stale data
+func (a *Arena) Free() {
+	for _, chunk := range a.allocator.chain.chunks {
+		clear(chunk)
+	}
+
+	a.allocator.Free()
+}
+
+func allocate(a *Arena, typ reflect.Type, count int) (unsafe.Pointer, error) {
+	if err := checkPointerFree(typ); err != nil {
+		return nil, err
+	}
+
+	size := 1 // zero size values still need a unique address
+	if typ.Size() != 0 {
+		if count > math.MaxInt/int(typ.Size()) {
+			return nil, ErrIncorrectSize
+		}
+
+		size = int(typ.Size()) * count
+	}
+
+	return a.allocator.AllocateAligned(size, typ.Align())
+}
+
+func checkPointerFree(typ reflect.Type) error {
+	if cached, found := pointerFreeTypes.Load(typ); found {
+		err, _ := cached.(error)
+		return err
+	}
+
+	var err error
+	if path, found := findPointer(typ, typ.String()); found {
+		err = fmt.Errorf("%w: %s", ErrPointerType, path)
+	}
+
+	pointerFreeTypes.Store(typ, err)
+	return err
+}
+
+// findPointer returns path to the first field with a pointer
+func findPointer(typ reflect.Type, path string) (string, bool) {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Map, reflect.Chan,
+		reflect.String, reflect.Interface, reflect.Slice, reflect.Func:
+		return fmt.Sprintf("%s (%s)", path, typ.Kind()), true
+	case reflect.Array:
+		return findPointer(typ.Elem(), path+"[]")
+	case reflect.Struct:
+		for idx := 0; idx < typ.NumField(); idx++ {
+			field := typ.Field(idx)
+			if fieldPath, found := findPointer(field.Type, path+"."+field.Name); found {
+				return fieldPath, true
+			}
+		}
+	}
+
+	return "", false
+}
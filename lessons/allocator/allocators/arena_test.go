@@ -0,0 +1,24 @@
+package allocators
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMakeSliceWithOverflow(t *testing.T) {
+	arena, err := NewArena(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer arena.Free()
+
+	if _, err := MakeSlice[int64](arena, 4, 1<<60+1); !errors.Is(err, ErrIncorrectSize) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	slice, err := MakeSlice[struct{}](arena, 4, 1<<60+1)
+	if err != nil || cap(slice) != 1<<60+1 {
+		t.Fatalf("unexpected result: %d, %v", cap(slice), err)
+	}
+}
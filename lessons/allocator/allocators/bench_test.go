@@ -0,0 +1,223 @@
+package allocators
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+// go test -bench=. -benchmem
+
+const (
+	traceLength  = 1000
+	maxBlockSize = 64
+)
+
+// operation allocates a block or releases
+// a block allocated by the operation with the index
+type operation struct {
+	size    int
+	release bool
+	index   int
+}
+
+// newLIFOTrace makes a trace suitable for all allocators,
+// blocks are released in reverse order of allocations
+func newLIFOTrace(seed int64) []operation {
+	random := rand.New(rand.NewSource(seed))
+	trace := make([]operation, 0, traceLength)
+	var live []operation
+	allocations := 0
+
+	for len(trace) < traceLength {
+		if len(live) != 0 && (random.Intn(3) == 0 || len(trace)+len(live) == traceLength) {
+			released := live[len(live)-1]
+			released.release = true
+			trace = append(trace, released)
+			live = live[:len(live)-1]
+			continue
+		}
+
+		allocation := operation{size: 1 + random.Intn(maxBlockSize), index: allocations}
+		trace = append(trace, allocation)
+		live = append(live, allocation)
+		allocations++
+	}
+
+	return trace
+}
+
+// replay returns the biggest difference between
+// reserved and requested bytes if it is measurable
+func replay(allocator Allocator, trace []operation, pointers []unsafe.Pointer) (int, error) {
+	defer allocator.Free()
+
+	meter, measurable := allocator.(Meter)
+	deallocator, _ := allocator.(Deallocator)
+	requested, peakWaste := 0, 0
+	pointers = pointers[:0]
+
+	for _, operation := range trace {
+		if operation.release {
+			requested -= operation.size
+			if deallocator != nil {
+				if err := deallocator.Deallocate(pointers[operation.index]); err != nil {
+					return 0, err
+				}
+			}
+
+			continue
+		}
+
+		pointer, err := allocator.Allocate(operation.size)
+		if err != nil {
+			return 0, err
+		}
+
+		*(*byte)(pointer) = byte(operation.size)
+		pointers = append(pointers, pointer)
+
+		requested += operation.size
+		if measurable {
+			peakWaste = max(peakWaste, meter.InUse()-requested)
+		}
+	}
+
+	return peakWaste, nil
+}
+
+type heapAllocator struct{}
+
+func (heapAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	return unsafe.Pointer(unsafe.SliceData(make([]byte, size))), nil
+}
+
+func (heapAllocator) Free() {}
+
+type syncPoolAllocator struct {
+	pool sync.Pool
+}
+
+func newSyncPoolAllocator() *syncPoolAllocator {
+	return &syncPoolAllocator{
+		pool: sync.Pool{
+			New: func() interface{} { return new([maxBlockSize]byte) },
+		},
+	}
+}
+
+func (a *syncPoolAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	if size > maxBlockSize {
+		return nil, ErrIncorrectSize
+	}
+
+	return unsafe.Pointer(a.pool.Get().(*[maxBlockSize]byte)), nil
+}
+
+func (a *syncPoolAllocator) Deallocate(pointer unsafe.Pointer) error {
+	a.pool.Put((*[maxBlockSize]byte)(pointer))
+	return nil
+}
+
+func (a *syncPoolAllocator) Free() {}
+
+type namedAllocator struct {
+	name      string
+	allocator Allocator
+}
+
+func newAllocators(tb testing.TB) []namedAllocator {
+	linear, err := NewLinearAllocator(traceLength * maxBlockSize / 4)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	stack, err := NewStackAllocator(traceLength * maxBlockSize / 4)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	pool, err := NewPoolAllocator(traceLength*maxBlockSize, maxBlockSize)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	freeList, err := NewFreeListAllocator(traceLength*(maxBlockSize+tagsSize), BestFit)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	buddy, err := NewBuddyAllocator(1<<17, 4)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	slab, err := NewSlabAllocator()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return []namedAllocator{
+		{name: "linear", allocator: linear},
+		{name: "stack", allocator: NewStackAdapter(stack, 8)},
+		{name: "pool", allocator: NewPoolAdapter(pool)},
+		{name: "free list", allocator: freeList},
+		{name: "buddy", allocator: buddy},
+		{name: "slab", allocator: slab},
+		{name: "new", allocator: heapAllocator{}},
+		{name: "sync.Pool", allocator: newSyncPoolAllocator()},
+	}
+}
+
+func TestReplay(t *testing.T) {
+	trace := newLIFOTrace(1)
+	for _, named := range newAllocators(t) {
+		allocator := named.allocator
+		t.Run(named.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ { // chunks and slots are reused
+				_, err := replay(allocator, trace, make([]unsafe.Pointer, 0, traceLength))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if meter, ok := allocator.(Meter); ok && meter.InUse() != 0 {
+				t.Errorf("%d bytes in use after Free", meter.InUse())
+			}
+		})
+	}
+}
+
+func BenchmarkAllocators(b *testing.B) {
+	trace := newLIFOTrace(1)
+	pointers := make([]unsafe.Pointer, 0, traceLength)
+
+	for _, named := range newAllocators(b) {
+		allocator := named.allocator
+		b.Run(named.name, func(b *testing.B) {
+			peakWaste, err := replay(allocator, trace, pointers)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, _ = replay(allocator, trace, pointers)
+			}
+
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+
+			if _, ok := allocator.(Meter); ok {
+				b.ReportMetric(float64(peakWaste), "wasted-bytes")
+			}
+
+			b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gc/op")
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package allocators
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"unsafe"
+)
+
+// BuddyAllocator splits the region into halves until a block
+// fits the requested size, so any block of order k has the only
+// buddy at offset ^ (1 << k) to be merged with after deallocation
+var _ Deallocator = (*BuddyAllocator)(nil)
+
+type BuddyAllocator struct {
+	memory   []byte
+	minOrder int
+	maxOrder int
+	used     int
+
+	// indexes below are numbers of minimal blocks
+	freeHeads       []int // per order
+	next            []int
+	previous        []int
+	freeOrders      []int8 // order of a free block starting at index or noBlock
+	allocatedOrders []int8 // order of an allocated block starting at index or noBlock
+}
+
+func NewBuddyAllocator(regionSize int, minOrder int) (*BuddyAllocator, error) {
+	if regionSize <= 0 || regionSize&(regionSize-1) != 0 {
+		return nil, errors.New("incorrect arguments")
+	}
+
+	// 1 << minOrder is zero for big orders, so orders are compared
+	maxOrder := bits.Len(uint(regionSize)) - 1
+	if minOrder < 0 || minOrder > maxOrder {
+		return nil, errors.New("incorrect arguments")
+	}
+
+	blocksNumber := regionSize >> minOrder
+	allocator := &BuddyAllocator{
+		memory:          make([]byte, regionSize),
+		minOrder:        minOrder,
+		maxOrder:        maxOrder,
+		freeHeads:       make([]int, maxOrder+1),
+		next:            make([]int, blocksNumber),
+		previous:        make([]int, blocksNumber),
+		freeOrders:      make([]int8, blocksNumber),
+		allocatedOrders: make([]int8, blocksNumber),
+	}
+
+	allocator.Free()
+	return allocator, nil
+}
+
+func (a *BuddyAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	if size <= 0 || size > len(a.memory) {
+		return nil, ErrIncorrectSize
+	}
+
+	order := max(a.minOrder, bits.Len(uint(size-1)))
+	current := order
+	for current <= a.maxOrder && a.freeHeads[current] == noBlock {
+		current++
+	}
+
+	if current > a.maxOrder {
+		return nil, ErrNotEnoughMemory
+	}
+
+	index := a.freeHeads[current]
+	a.remove(index, current)
+	for current > order {
+		current--
+		a.push(index+a.blocks(current), current)
+	}
+
+	a.allocatedOrders[index] = int8(order)
+	a.used += 1 << order
+	return unsafe.Pointer(&a.memory[index<<a.minOrder]), nil
+}
+
+func (a *BuddyAllocator) Deallocate(pointer unsafe.Pointer) error {
+	if pointer == nil {
+		return ErrIncorrectPointer
+	}
+
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(a.memory)))
+	if uintptr(pointer) < base || uintptr(pointer) >= base+uintptr(len(a.memory)) {
+		return &PointerError{Pointer: pointer, Err: ErrOutOfRegion}
+	}
+
+	offset := int(uintptr(pointer) - base)
+	if offset&(1<<a.minOrder-1) != 0 {
+		return &PointerError{Pointer: pointer, Err: ErrNotObjectBoundary}
+	}
+
+	index := offset >> a.minOrder
+	order := int(a.allocatedOrders[index])
+	if order == noBlock {
+		return &PointerError{Pointer: pointer, Err: ErrDoubleFree}
+	}
+
+	a.allocatedOrders[index] = noBlock
+	a.used -= 1 << order
+	for order < a.maxOrder {
+		buddy := index ^ a.blocks(order)
+		if int(a.freeOrders[buddy]) != order {
+			break
+		}
+
+		a.remove(buddy, order)
+		index = min(index, buddy)
+		order++
+	}
+
+	a.push(index, order)
+	return nil
+}
+
+func (a *BuddyAllocator) Free() {
+	for order := range a.freeHeads {
+		a.freeHeads[order] = noBlock
+	}
+
+	for index := range a.freeOrders {
+		a.freeOrders[index] = noBlock
+		a.allocatedOrders[index] = noBlock
+	}
+
+	a.used = 0
+	a.push(0, a.maxOrder)
+}
+
+func (a *BuddyAllocator) InUse() int {
+	return a.used
+}
+
+// Dump renders the current split tree, for example:
+//
+//	[0, 1024) split
+//	  [0, 512) split
+//	    [0, 256) allocated
+//	    [256, 512) free
+//	  [512, 1024) free
+func (a *BuddyAllocator) Dump() string {
+	var builder strings.Builder
+	a.dump(&builder, 0, a.maxOrder, 0)
+	return builder.String()
+}
+
+func (a *BuddyAllocator) dump(builder *strings.Builder, index int, order int, depth int) {
+	offset := index << a.minOrder
+	state := "split"
+	switch order {
+	case int(a.freeOrders[index]):
+		state = "free"
+	case int(a.allocatedOrders[index]):
+		state = "allocated"
+	}
+
+	fmt.Fprintf(builder, "%s[%d, %d) %s\n", strings.Repeat("  ", depth), offset, offset+1<<order, state)
+	if state == "split" {
+		a.dump(builder, index, order-1, depth+1)
+		a.dump(builder, index+a.blocks(order-1), order-1, depth+1)
+	}
+}
+
+// blocks returns number of minimal blocks in a block of the order
+func (a *BuddyAllocator) blocks(order int) int {
+	return 1 << (order - a.minOrder)
+}
+
+func (a *BuddyAllocator) push(index int, order int) {
+	head := a.freeHeads[order]
+	a.next[index] = head
+	a.previous[index] = noBlock
+	if head != noBlock {
+		a.previous[head] = index
+	}
+
+	a.freeHeads[order] = index
+	a.freeOrders[index] = int8(order)
+}
+
+func (a *BuddyAllocator) remove(index int, order int) {
+	next, previous := a.next[index], a.previous[index]
+	if previous != noBlock {
+		a.next[previous] = next
+	} else {
+		a.freeHeads[order] = next
+	}
+
+	if next != noBlock {
+		a.previous[next] = previous
+	}
+
+	a.freeOrders[index] = noBlock
+}
@@ -0,0 +1,71 @@
+package allocators
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestBuddyAllocatorWithIncorrectOrder(t *testing.T) {
+	for _, minOrder := range []int{-1, 11, 64, 70} {
+		if _, err := NewBuddyAllocator(1<<10, minOrder); err == nil {
+			t.Fatalf("order %d is accepted", minOrder)
+		}
+	}
+
+	if _, err := NewBuddyAllocator(1<<10, 10); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuddyAllocatorMerges(t *testing.T) {
+	allocator, err := NewBuddyAllocator(64, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointer1, _ := allocator.Allocate(10)
+	pointer2, _ := allocator.Allocate(16)
+
+	expected := "[0, 64) split\n" +
+		"  [0, 32) split\n" +
+		"    [0, 16) allocated\n" +
+		"    [16, 32) allocated\n" +
+		"  [32, 64) free\n"
+	if dump := allocator.Dump(); dump != expected {
+		t.Fatalf("unexpected tree:\n%s", dump)
+	}
+
+	if allocator.InUse() != 32 {
+		t.Fatalf("unexpected memory in use: %d", allocator.InUse())
+	}
+
+	if err := allocator.Deallocate(pointer1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := allocator.Deallocate(pointer1); !errors.Is(err, ErrDoubleFree) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := allocator.Deallocate(unsafe.Add(pointer2, 1)); !errors.Is(err, ErrNotObjectBoundary) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// buddies are merged up to the whole region
+	if err := allocator.Deallocate(pointer2); err != nil {
+		t.Fatal(err)
+	}
+
+	if dump := allocator.Dump(); dump != "[0, 64) free\n" {
+		t.Fatalf("unexpected tree:\n%s", dump)
+	}
+
+	if _, err := allocator.Allocate(64); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(1); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
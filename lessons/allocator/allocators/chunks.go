@@ -0,0 +1,119 @@
+package allocators
+
+import "math"
+
+// maxChunkSize keeps make from a panic on huge sizes when
+// the total size is not limited, a bigger block is not allocated
+const maxChunkSize = 1 << 40
+
+// GrowthPolicy returns size of the next chunk by size of the last one
+type GrowthPolicy func(lastChunkSize int) int
+
+func ConstantGrowth(lastChunkSize int) int {
+	return lastChunkSize
+}
+
+func DoubleGrowth(lastChunkSize int) int {
+	return lastChunkSize * 2
+}
+
+type ChunkOption func(*chunkChain)
+
+// WithMaxSize limits total size of all chunks
+func WithMaxSize(maxSize int) ChunkOption {
+	return func(chain *chunkChain) {
+		chain.maxSize = maxSize
+	}
+}
+
+func WithGrowthPolicy(policy GrowthPolicy) ChunkOption {
+	return func(chain *chunkChain) {
+		chain.growth = policy
+	}
+}
+
+// chunkChain never copies chunks, so pointers stay
+// valid when a new chunk is added to the chain
+type chunkChain struct {
+	chunks    [][]byte
+	current   int
+	totalSize int
+	maxSize   int
+	growth    GrowthPolicy
+}
+
+func newChunkChain(capacity int, options []ChunkOption) (chunkChain, error) {
+	if capacity <= 0 || capacity > maxChunkSize {
+		return chunkChain{}, ErrIncorrectCapacity
+	}
+
+	chain := chunkChain{
+		maxSize: math.MaxInt,
+		growth:  DoubleGrowth,
+	}
+
+	for _, option := range options {
+		option(&chain)
+	}
+
+	if chain.maxSize < capacity || chain.growth == nil {
+		return chunkChain{}, ErrIncorrectCapacity
+	}
+
+	chain.chunks = [][]byte{make([]byte, 0, capacity)}
+	chain.totalSize = capacity
+	return chain, nil
+}
+
+// next switches to the next kept chunk
+// or adds a new chunk to the end of the chain
+func (c *chunkChain) next(size int) error {
+	// kept chunks are always empty, but can be too small
+	for idx := c.current + 1; idx < len(c.chunks); idx++ {
+		if cap(c.chunks[idx]) >= size {
+			c.current++
+			c.chunks[c.current], c.chunks[idx] = c.chunks[idx], c.chunks[c.current]
+			return nil
+		}
+	}
+
+	if size > maxChunkSize {
+		return ErrNotEnoughMemory
+	}
+
+	lastChunkSize := cap(c.chunks[len(c.chunks)-1])
+	chunkSize := min(max(c.growth(lastChunkSize), size), maxChunkSize)
+	if chunkSize > c.maxSize-c.totalSize {
+		chunkSize = c.maxSize - c.totalSize
+		if chunkSize < size {
+			return ErrNotEnoughMemory
+		}
+	}
+
+	c.chunks = append(c.chunks, make([]byte, 0, chunkSize))
+	c.totalSize += chunkSize
+	c.current++
+
+	last := len(c.chunks) - 1
+	c.chunks[c.current], c.chunks[last] = c.chunks[last], c.chunks[c.current]
+	return nil
+}
+
+// reset returns to the first chunk, other
+// chunks are kept for next allocations
+func (c *chunkChain) reset() {
+	for idx := range c.chunks {
+		c.chunks[idx] = c.chunks[idx][:0]
+	}
+
+	c.current = 0
+}
+
+func (c *chunkChain) inUse() int {
+	used := 0
+	for _, chunk := range c.chunks[:c.current+1] {
+		used += len(chunk)
+	}
+
+	return used
+}
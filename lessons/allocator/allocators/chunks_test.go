@@ -0,0 +1,34 @@
+package allocators
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChunksWithHugeSizes(t *testing.T) {
+	linear, err := NewLinearAllocator(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := linear.Allocate(1 << 62); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := linear.AllocateAligned(8, 1<<62); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stack, err := NewStackAllocator(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stack.Allocate(8, 1<<62); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := NewLinearAllocator(1 << 62); !errors.Is(err, ErrIncorrectCapacity) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package allocators
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+const (
+	guardSize     = 16 // keeps alignment of blocks
+	canaryByte    = 0xCA
+	poisonByte    = 0xDD
+	maxStackDepth = 16
+)
+
+var ErrUnknownBlock = errors.New("block is not allocated by the debug allocator")
+
+// CorruptionError means that a caller wrote
+// outside of the block and damaged its guard bytes
+type CorruptionError struct {
+	Pointer unsafe.Pointer
+	Size    int
+	Before  bool // guard before the block is damaged
+	After   bool // guard after the block is damaged
+	Site    string
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("guard bytes of block %p (%d bytes) are damaged, before: %t, after: %t, allocated at:\n%s",
+		e.Pointer, e.Size, e.Before, e.After, e.Site)
+}
+
+// Leak describes a block which was not deallocated before Free
+type Leak struct {
+	Pointer unsafe.Pointer
+	Size    int
+	Site    string
+}
+
+func (l Leak) String() string {
+	return fmt.Sprintf("%d bytes at %p allocated at:\n%s", l.Size, l.Pointer, l.Site)
+}
+
+type debugBlock struct {
+	inner   unsafe.Pointer
+	size    int
+	callers []uintptr
+}
+
+type DebugOption func(*DebugAllocator)
+
+// WithLeakReporter replaces printing of leaks to stderr
+func WithLeakReporter(reporter func([]Leak)) DebugOption {
+	return func(allocator *DebugAllocator) {
+		allocator.reporter = reporter
+	}
+}
+
+// DebugAllocator surrounds every block of the wrapped allocator with
+// canary bytes, poisons released memory and reports leaks on Free.
+// The wrapped allocator has to serve blocks bigger by 2*16 bytes.
+type DebugAllocator struct {
+	allocator Allocator
+	blocks    map[unsafe.Pointer]*debugBlock
+	reporter  func([]Leak)
+}
+
+var _ Deallocator = (*DebugAllocator)(nil)
+
+func NewDebugAllocator(allocator Allocator, options ...DebugOption) *DebugAllocator {
+	debugAllocator := &DebugAllocator{
+		allocator: allocator,
+		blocks:    make(map[unsafe.Pointer]*debugBlock),
+		reporter:  printLeaks,
+	}
+
+	for _, option := range options {
+		option(debugAllocator)
+	}
+
+	return debugAllocator
+}
+
+func (a *DebugAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	if size <= 0 {
+		return nil, ErrIncorrectSize
+	}
+
+	inner, err := a.allocator.Allocate(size + 2*guardSize)
+	if err != nil {
+		return nil, err
+	}
+
+	memory := unsafe.Slice((*byte)(inner), size+2*guardSize)
+	fill(memory[:guardSize], canaryByte)
+	fill(memory[guardSize+size:], canaryByte)
+
+	callers := make([]uintptr, maxStackDepth)
+	callers = callers[:runtime.Callers(2, callers)]
+
+	pointer := unsafe.Add(inner, guardSize)
+	a.blocks[pointer] = &debugBlock{
+		inner:   inner,
+		size:    size,
+		callers: callers,
+	}
+
+	return pointer, nil
+}
+
+// Deallocate checks guard bytes and poisons the block, the block is
+// not returned to the wrapped allocator if the guards are damaged.
+// A block rejected by the wrapped allocator stays live and untouched.
+func (a *DebugAllocator) Deallocate(pointer unsafe.Pointer) error {
+	block, found := a.blocks[pointer]
+	if !found {
+		return &PointerError{Pointer: pointer, Err: ErrUnknownBlock}
+	}
+
+	if err := a.check(pointer, block); err != nil {
+		return err
+	}
+
+	memory := unsafe.Slice((*byte)(block.inner), block.size+2*guardSize)
+	deallocator, ok := a.allocator.(Deallocator)
+	if !ok {
+		delete(a.blocks, pointer)
+		fill(memory, poisonByte)
+		return nil
+	}
+
+	// the block is poisoned before the wrapped allocator
+	// writes its own data like links of free objects into it
+	saved := bytes.Clone(memory)
+	fill(memory, poisonByte)
+	if err := deallocator.Deallocate(block.inner); err != nil {
+		copy(memory, saved)
+		return err
+	}
+
+	delete(a.blocks, pointer)
+	return nil
+}
+
+// Check verifies guard bytes of all live blocks
+func (a *DebugAllocator) Check() error {
+	var errs []error
+	for pointer, block := range a.blocks {
+		if err := a.check(pointer, block); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *DebugAllocator) Leaks() []Leak {
+	leaks := make([]Leak, 0, len(a.blocks))
+	for pointer, block := range a.blocks {
+		leaks = append(leaks, Leak{
+			Pointer: pointer,
+			Size:    block.size,
+			Site:    site(block.callers),
+		})
+	}
+
+	return leaks
+}
+
+// Free reports leaked blocks, poisons them and frees the wrapped allocator
+func (a *DebugAllocator) Free() {
+	if leaks := a.Leaks(); len(leaks) != 0 {
+		a.reporter(leaks)
+	}
+
+	for _, block := range a.blocks {
+		fill(unsafe.Slice((*byte)(block.inner), block.size+2*guardSize), poisonByte)
+	}
+
+	clear(a.blocks)
+	a.allocator.Free()
+}
+
+func (a *DebugAllocator) InUse() int {
+	if meter, ok := a.allocator.(Meter); ok {
+		return meter.InUse()
+	}
+
+	return 0
+}
+
+func (a *DebugAllocator) check(pointer unsafe.Pointer, block *debugBlock) error {
+	memory := unsafe.Slice((*byte)(block.inner), block.size+2*guardSize)
+	before := bytes.Count(memory[:guardSize], []byte{canaryByte}) != guardSize
+	after := bytes.Count(memory[guardSize+block.size:], []byte{canaryByte}) != guardSize
+	if !before && !after {
+		return nil
+	}
+
+	return &CorruptionError{
+		Pointer: pointer,
+		Size:    block.size,
+		Before:  before,
+		After:   after,
+		Site:    site(block.callers),
+	}
+}
+
+func fill(memory []byte, value byte) {
+	for idx := range memory {
+		memory[idx] = value
+	}
+}
+
+func site(callers []uintptr) string {
+	var builder strings.Builder
+	frames := runtime.CallersFrames(callers)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&builder, "\t%s\n\t\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+
+	return builder.String()
+}
+
+func printLeaks(leaks []Leak) {
+	fmt.Fprintf(os.Stderr, "%d blocks were not deallocated:\n", len(leaks))
+	for _, leak := range leaks {
+		fmt.Fprintln(os.Stderr, leak)
+	}
+}
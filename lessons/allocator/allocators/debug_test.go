@@ -0,0 +1,125 @@
+package allocators
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestDebugAllocatorWithOverflow(t *testing.T) {
+	stack, err := NewStackAllocator(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the damaged block is not released
+	allocator := NewDebugAllocator(NewStackAdapter(stack, 8), WithLeakReporter(func([]Leak) {}))
+	defer allocator.Free()
+
+	pointer, err := allocator.Allocate(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Store[int64](pointer, 100)
+	Store[int64](unsafe.Add(pointer, 8), 200) // out of the block
+
+	var corruption *CorruptionError
+	if err := allocator.Deallocate(pointer); !errors.As(err, &corruption) || corruption.Before || !corruption.After {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDebugAllocatorWithPoisoning(t *testing.T) {
+	pool, err := NewPoolAllocator(1<<10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allocator := NewDebugAllocator(NewPoolAdapter(pool))
+	defer allocator.Free()
+
+	pointer, err := allocator.Allocate(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Store[int64](pointer, 100)
+	if err := allocator.Deallocate(pointer); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := Load[uint64](pointer); value != 0xDDDDDDDDDDDDDDDD {
+		t.Fatalf("memory is not poisoned: %x", value)
+	}
+
+	if err := allocator.Deallocate(pointer); !errors.Is(err, ErrUnknownBlock) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDebugAllocatorWithLeaks(t *testing.T) {
+	pool, err := NewPoolAllocator(1<<10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var leaks []Leak
+	allocator := NewDebugAllocator(NewPoolAdapter(pool), WithLeakReporter(func(reported []Leak) {
+		leaks = reported
+	}))
+
+	pointer1, _ := allocator.Allocate(8)
+	pointer2, _ := allocator.Allocate(8)
+	_ = allocator.Deallocate(pointer1)
+	allocator.Free()
+
+	if len(leaks) != 1 || leaks[0].Pointer != pointer2 || leaks[0].Size != 8 {
+		t.Fatalf("unexpected leaks: %v", leaks)
+	}
+
+	if pool.InUse() != 0 {
+		t.Fatal("pool is not freed")
+	}
+}
+
+func TestDebugAllocatorWithRejectedDeallocation(t *testing.T) {
+	stack, err := NewStackAllocator(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var leaks []Leak
+	allocator := NewDebugAllocator(NewStackAdapter(stack, 8), WithLeakReporter(func(reported []Leak) {
+		leaks = reported
+	}))
+
+	pointer1, _ := allocator.Allocate(8)
+	pointer2, _ := allocator.Allocate(8)
+	Store[int64](pointer1, 100)
+
+	if err := allocator.Deallocate(pointer1); !errors.Is(err, ErrNotTopOfStack) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if value := Load[int64](pointer1); value != 100 {
+		t.Fatalf("rejected block is changed: %x", value)
+	}
+
+	if err := allocator.Deallocate(pointer2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := allocator.Deallocate(pointer1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(8); err != nil {
+		t.Fatal(err)
+	}
+
+	allocator.Free()
+	if len(leaks) != 1 {
+		t.Fatalf("unexpected leaks: %v", leaks)
+	}
+}
@@ -0,0 +1,326 @@
+package allocators
+
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+// block = header + payload + footer, header and footer (boundary tags)
+// keep size of the whole block with the lowest bit as allocation flag,
+// payload of a free block keeps offsets of neighbours in the free list
+const (
+	wordSize       = 8
+	tagsSize       = 2 * wordSize
+	minBlockSize   = tagsSize + 2*wordSize
+	allocatedFlag  = 1
+	noBlock        = -1
+	nextLinkOffset = wordSize
+	prevLinkOffset = 2 * wordSize
+)
+
+type FitPolicy int
+
+const (
+	FirstFit FitPolicy = iota
+	BestFit
+)
+
+type FragmentationStats struct {
+	FreeBytes             int
+	LargestFreeBlock      int
+	FreeBlocks            int
+	AllocatedBlocks       int
+	ExternalFragmentation float64 // 1 - largest free block / all free bytes
+}
+
+var _ Deallocator = (*FreeListAllocator)(nil)
+
+type FreeListAllocator struct {
+	data     []byte
+	start    int // first aligned offset
+	end      int
+	freeHead int
+	policy   FitPolicy
+	used     int // sizes of allocated blocks
+}
+
+func NewFreeListAllocator(capacity int, policy FitPolicy) (*FreeListAllocator, error) {
+	if capacity < minBlockSize || (policy != FirstFit && policy != BestFit) {
+		return nil, errors.New("incorrect arguments")
+	}
+
+	data := make([]byte, capacity+wordSize-1)
+	address := uintptr(unsafe.Pointer(unsafe.SliceData(data)))
+	start := int((address+wordSize-1)&^(wordSize-1) - address)
+
+	allocator := &FreeListAllocator{
+		data:   data,
+		start:  start,
+		end:    start + capacity&^(wordSize-1),
+		policy: policy,
+	}
+
+	allocator.Free()
+	return allocator, nil
+}
+
+func (a *FreeListAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	if size <= 0 {
+		return nil, ErrIncorrectSize
+	}
+
+	// rounding of a bigger size can overflow
+	if size > a.end-a.start-tagsSize {
+		return nil, ErrNotEnoughMemory
+	}
+
+	blockSize := max(alignUp(size)+tagsSize, minBlockSize)
+	offset := a.find(blockSize)
+	if offset == noBlock {
+		// can increase capacity
+		return nil, ErrNotEnoughMemory
+	}
+
+	a.remove(offset)
+	a.split(offset, blockSize)
+	a.used += a.size(offset)
+	return unsafe.Pointer(&a.data[offset+wordSize]), nil
+}
+
+func (a *FreeListAllocator) Deallocate(pointer unsafe.Pointer) error {
+	offset, err := a.blockOffset(pointer)
+	if err != nil {
+		return err
+	}
+
+	// tags inside of a coalesced block help to catch double free
+	size := a.size(offset)
+	a.used -= size
+	a.setTags(offset, size, false)
+
+	if next := offset + size; next < a.end && !a.allocated(next) {
+		a.remove(next)
+		size += a.size(next)
+	}
+
+	if offset > a.start && !a.allocated(offset-wordSize) {
+		previous := offset - a.size(offset-wordSize)
+		a.remove(previous)
+		size += offset - previous
+		offset = previous
+	}
+
+	a.setTags(offset, size, false)
+	a.insert(offset)
+	return nil
+}
+
+// Realloc changes size of a block in place if it is possible,
+// otherwise data is moved to a new block
+func (a *FreeListAllocator) Realloc(pointer unsafe.Pointer, size int) (unsafe.Pointer, error) {
+	if pointer == nil {
+		return a.Allocate(size)
+	}
+
+	if size <= 0 {
+		return nil, ErrIncorrectSize
+	}
+
+	offset, err := a.blockOffset(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	if size > a.end-a.start-tagsSize {
+		return nil, ErrNotEnoughMemory
+	}
+
+	blockSize := max(alignUp(size)+tagsSize, minBlockSize)
+	previousSize := a.size(offset)
+	currentSize := previousSize
+	if next := offset + currentSize; currentSize < blockSize && next < a.end && !a.allocated(next) {
+		if nextSize := a.size(next); currentSize+nextSize >= blockSize {
+			a.remove(next)
+			currentSize += nextSize
+			a.setTags(offset, currentSize, true)
+		}
+	}
+
+	if currentSize >= blockSize {
+		a.split(offset, blockSize)
+		a.used += a.size(offset) - previousSize
+		return pointer, nil
+	}
+
+	newPointer, err := a.Allocate(size)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadSize := currentSize - tagsSize
+	copy(unsafe.Slice((*byte)(newPointer), payloadSize), unsafe.Slice((*byte)(pointer), payloadSize))
+	_ = a.Deallocate(pointer)
+	return newPointer, nil
+}
+
+func (a *FreeListAllocator) Free() {
+	a.freeHead = noBlock
+	a.used = 0
+	a.setTags(a.start, a.end-a.start, false)
+	a.insert(a.start)
+}
+
+// InUse includes tags and padding of allocated blocks
+func (a *FreeListAllocator) InUse() int {
+	return a.used
+}
+
+func (a *FreeListAllocator) Stats() FragmentationStats {
+	var stats FragmentationStats
+	for offset := a.start; offset < a.end; offset += a.size(offset) {
+		if a.allocated(offset) {
+			stats.AllocatedBlocks++
+			continue
+		}
+
+		size := a.size(offset) - tagsSize
+		stats.FreeBlocks++
+		stats.FreeBytes += size
+		stats.LargestFreeBlock = max(stats.LargestFreeBlock, size)
+	}
+
+	if stats.FreeBytes != 0 {
+		stats.ExternalFragmentation = 1 - float64(stats.LargestFreeBlock)/float64(stats.FreeBytes)
+	}
+
+	return stats
+}
+
+func (a *FreeListAllocator) find(blockSize int) int {
+	found := noBlock
+	for offset := a.freeHead; offset != noBlock; offset = a.link(offset, nextLinkOffset) {
+		size := a.size(offset)
+		if size < blockSize {
+			continue
+		}
+
+		if a.policy == FirstFit || size == blockSize {
+			return offset
+		}
+
+		if found == noBlock || size < a.size(found) {
+			found = offset
+		}
+	}
+
+	return found
+}
+
+// split marks the beginning of a block as allocated
+// and returns the rest of it to the free list
+func (a *FreeListAllocator) split(offset int, blockSize int) {
+	size := a.size(offset)
+	if size-blockSize < minBlockSize {
+		a.setTags(offset, size, true)
+		return
+	}
+
+	a.setTags(offset, blockSize, true)
+
+	rest := offset + blockSize
+	restSize := size - blockSize
+	if next := rest + restSize; next < a.end && !a.allocated(next) {
+		a.remove(next)
+		restSize += a.size(next)
+	}
+
+	a.setTags(rest, restSize, false)
+	a.insert(rest)
+}
+
+func (a *FreeListAllocator) blockOffset(pointer unsafe.Pointer) (int, error) {
+	if pointer == nil {
+		return 0, ErrIncorrectPointer
+	}
+
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(a.data)))
+	if uintptr(pointer) < base+uintptr(a.start+wordSize) || uintptr(pointer) >= base+uintptr(a.end) {
+		return 0, &PointerError{Pointer: pointer, Err: ErrOutOfRegion}
+	}
+
+	offset := int(uintptr(pointer)-base) - wordSize
+	if (offset-a.start)%wordSize != 0 {
+		return 0, &PointerError{Pointer: pointer, Err: ErrNotObjectBoundary}
+	}
+
+	size := a.size(offset)
+	if size < minBlockSize || offset+size > a.end || a.word(offset) != a.word(offset+size-wordSize) {
+		return 0, &PointerError{Pointer: pointer, Err: ErrNotObjectBoundary}
+	}
+
+	if !a.allocated(offset) {
+		return 0, &PointerError{Pointer: pointer, Err: ErrDoubleFree}
+	}
+
+	return offset, nil
+}
+
+func (a *FreeListAllocator) insert(offset int) {
+	a.setLink(offset, nextLinkOffset, a.freeHead)
+	a.setLink(offset, prevLinkOffset, noBlock)
+	if a.freeHead != noBlock {
+		a.setLink(a.freeHead, prevLinkOffset, offset)
+	}
+
+	a.freeHead = offset
+}
+
+func (a *FreeListAllocator) remove(offset int) {
+	next := a.link(offset, nextLinkOffset)
+	previous := a.link(offset, prevLinkOffset)
+	if previous != noBlock {
+		a.setLink(previous, nextLinkOffset, next)
+	} else {
+		a.freeHead = next
+	}
+
+	if next != noBlock {
+		a.setLink(next, prevLinkOffset, previous)
+	}
+}
+
+func (a *FreeListAllocator) setTags(offset int, size int, allocated bool) {
+	tag := uint64(size)
+	if allocated {
+		tag |= allocatedFlag
+	}
+
+	binary.LittleEndian.PutUint64(a.data[offset:], tag)
+	binary.LittleEndian.PutUint64(a.data[offset+size-wordSize:], tag)
+}
+
+// size and allocated accept offset of a header or a footer
+func (a *FreeListAllocator) size(offset int) int {
+	return int(a.word(offset) &^ allocatedFlag)
+}
+
+func (a *FreeListAllocator) allocated(offset int) bool {
+	return a.word(offset)&allocatedFlag != 0
+}
+
+func (a *FreeListAllocator) word(offset int) uint64 {
+	return binary.LittleEndian.Uint64(a.data[offset:])
+}
+
+func (a *FreeListAllocator) link(offset int, linkOffset int) int {
+	return int(int64(a.word(offset + linkOffset)))
+}
+
+func (a *FreeListAllocator) setLink(offset int, linkOffset int, value int) {
+	binary.LittleEndian.PutUint64(a.data[offset+linkOffset:], uint64(int64(value)))
+}
+
+func alignUp(size int) int {
+	return (size + wordSize - 1) &^ (wordSize - 1)
+}
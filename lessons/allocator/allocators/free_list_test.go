@@ -0,0 +1,135 @@
+package allocators
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFreeListAllocatorWithOverflow(t *testing.T) {
+	allocator, err := NewFreeListAllocator(1<<10, FirstFit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(math.MaxInt - 3); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pointer, err := allocator.Allocate(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Realloc(pointer, math.MaxInt-3); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFreeListAllocatorCoalescing(t *testing.T) {
+	allocator, err := NewFreeListAllocator(1<<10, FirstFit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointer1, _ := allocator.Allocate(16)
+	pointer2, _ := allocator.Allocate(16)
+	pointer3, _ := allocator.Allocate(16)
+
+	if err := allocator.Deallocate(pointer1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := allocator.Deallocate(pointer3); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := allocator.Stats(); stats.FreeBlocks != 2 || stats.AllocatedBlocks != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if err := allocator.Deallocate(pointer2); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := allocator.Stats()
+	if stats.FreeBlocks != 1 || stats.LargestFreeBlock != 1<<10-tagsSize || stats.ExternalFragmentation != 0 {
+		t.Fatalf("blocks are not coalesced: %+v", stats)
+	}
+
+	if err := allocator.Deallocate(pointer2); !errors.Is(err, ErrDoubleFree) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if allocator.InUse() != 0 {
+		t.Fatalf("unexpected memory in use: %d", allocator.InUse())
+	}
+}
+
+func TestFreeListAllocatorRealloc(t *testing.T) {
+	allocator, err := NewFreeListAllocator(1<<10, FirstFit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointer1, _ := allocator.Allocate(8)
+	_, _ = allocator.Allocate(8)
+	Store[int64](pointer1, 100)
+
+	// the next block is allocated
+	moved, err := allocator.Realloc(pointer1, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moved == pointer1 || Load[int64](moved) != 100 {
+		t.Fatalf("block is not moved: %p, %d", moved, Load[int64](moved))
+	}
+
+	// the rest of the memory follows the moved block
+	grown, err := allocator.Realloc(moved, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if grown != moved || Load[int64](grown) != 100 {
+		t.Fatalf("block is not grown in place: %p, %d", grown, Load[int64](grown))
+	}
+
+	if allocator.InUse() != minBlockSize+128+tagsSize {
+		t.Fatalf("unexpected memory in use: %d", allocator.InUse())
+	}
+}
+
+func TestFreeListAllocatorFitPolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy FitPolicy
+		large  bool // the first free block is chosen
+	}{
+		{policy: FirstFit, large: true},
+		{policy: BestFit, large: false},
+	} {
+		allocator, err := NewFreeListAllocator(1<<10, test.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		large, _ := allocator.Allocate(64)
+		_, _ = allocator.Allocate(8)
+		small, _ := allocator.Allocate(16)
+		_, _ = allocator.Allocate(8)
+
+		// the large block is on the head of the free list
+		_ = allocator.Deallocate(small)
+		_ = allocator.Deallocate(large)
+
+		pointer, err := allocator.Allocate(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if (pointer == large) != test.large || (pointer == small) == test.large {
+			t.Fatalf("unexpected block for policy %d", test.policy)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package allocators
+
+import (
+	"math"
+	"unsafe"
+)
+
+var _ Allocator = (*LinearAllocator)(nil)
+
+type LinearAllocator struct {
+	chain chunkChain
+}
+
+func NewLinearAllocator(capacity int, options ...ChunkOption) (*LinearAllocator, error) {
+	chain, err := newChunkChain(capacity, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LinearAllocator{chain: chain}, nil
+}
+
+func (a *LinearAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	return a.AllocateAligned(size, 1)
+}
+
+func (a *LinearAllocator) AllocateAligned(size int, align int) (unsafe.Pointer, error) {
+	if size <= 0 {
+		return nil, ErrIncorrectSize
+	}
+
+	if align <= 0 || align&(align-1) != 0 {
+		return nil, ErrIncorrectAlignment
+	}
+
+	chunk := a.chain.chunks[a.chain.current]
+	offset := alignedOffset(chunk, align)
+	if size > cap(chunk)-offset {
+		if size > math.MaxInt-align {
+			return nil, ErrNotEnoughMemory
+		}
+
+		// a new chunk can need up to align-1 bytes of padding
+		if err := a.chain.next(size + align - 1); err != nil {
+			return nil, err
+		}
+
+		chunk = a.chain.chunks[a.chain.current]
+		offset = alignedOffset(chunk, align)
+	}
+
+	chunk = chunk[:offset+size]
+	a.chain.chunks[a.chain.current] = chunk
+	return unsafe.Pointer(&chunk[offset]), nil
+}
+
+func alignedOffset(chunk []byte, align int) int {
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(chunk)))
+	address := base + uintptr(len(chunk))
+	return int((address+uintptr(align)-1)&^(uintptr(align)-1) - base)
+}
+
+// not supported by this kind of allocator
+// func (a *LinearAllocator) Deallocate(pointer unsafe.Pointer) error {}
+
+func (a *LinearAllocator) Free() {
+	a.chain.reset()
+}
+
+func (a *LinearAllocator) InUse() int {
+	return a.chain.inUse()
+}
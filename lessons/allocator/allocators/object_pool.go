@@ -0,0 +1,156 @@
+package allocators
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+type PoolStats struct {
+	Hits   uint64 // objects reused from the pool
+	Misses uint64 // objects constructed by Get
+	Drops  uint64 // objects rejected by validation on Put or dropped by a full BoundedPool
+}
+
+type PoolOption[T any] func(*poolHooks[T])
+
+// WithValidation drops objects for which the function returns false,
+// for example buffers whose capacity grew past a limit
+func WithValidation[T any](validate func(*T) bool) PoolOption[T] {
+	return func(hooks *poolHooks[T]) {
+		hooks.validate = validate
+	}
+}
+
+type poolHooks[T any] struct {
+	construct func() *T
+	reset     func(*T)
+	validate  func(*T) bool
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	drops  atomic.Uint64
+}
+
+func newPoolHooks[T any](construct func() *T, reset func(*T), options []PoolOption[T]) (*poolHooks[T], error) {
+	if construct == nil || reset == nil {
+		return nil, errors.New("constructor and reset function are required")
+	}
+
+	hooks := &poolHooks[T]{
+		construct: construct,
+		reset:     reset,
+	}
+
+	for _, option := range options {
+		option(hooks)
+	}
+
+	return hooks, nil
+}
+
+// accept resets an object if it can be returned to the pool
+func (h *poolHooks[T]) accept(object *T) bool {
+	if h.validate != nil && !h.validate(object) {
+		h.drops.Add(1)
+		return false
+	}
+
+	h.reset(object)
+	return true
+}
+
+func (h *poolHooks[T]) stats() PoolStats {
+	return PoolStats{
+		Hits:   h.hits.Load(),
+		Misses: h.misses.Load(),
+		Drops:  h.drops.Load(),
+	}
+}
+
+// Pool is a typed wrapper of sync.Pool, objects are
+// reset on Put and can be cleared by the garbage collector
+type Pool[T any] struct {
+	pool  sync.Pool
+	hooks *poolHooks[T]
+}
+
+func NewPool[T any](construct func() *T, reset func(*T), options ...PoolOption[T]) (*Pool[T], error) {
+	hooks, err := newPoolHooks(construct, reset, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pool[T]{hooks: hooks}, nil
+}
+
+func (p *Pool[T]) Get() *T {
+	if object, ok := p.pool.Get().(*T); ok {
+		p.hooks.hits.Add(1)
+		return object
+	}
+
+	p.hooks.misses.Add(1)
+	return p.hooks.construct()
+}
+
+func (p *Pool[T]) Put(object *T) {
+	if object != nil && p.hooks.accept(object) {
+		p.pool.Put(object)
+	}
+}
+
+func (p *Pool[T]) Stats() PoolStats {
+	return p.hooks.stats()
+}
+
+// BoundedPool keeps at most capacity objects which are never cleared
+// by the garbage collector, it suits objects expensive to construct
+type BoundedPool[T any] struct {
+	objects chan *T
+	hooks   *poolHooks[T]
+}
+
+func NewBoundedPool[T any](capacity int, construct func() *T, reset func(*T), options ...PoolOption[T]) (*BoundedPool[T], error) {
+	if capacity <= 0 {
+		return nil, ErrIncorrectCapacity
+	}
+
+	hooks, err := newPoolHooks(construct, reset, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoundedPool[T]{
+		objects: make(chan *T, capacity),
+		hooks:   hooks,
+	}, nil
+}
+
+func (p *BoundedPool[T]) Get() *T {
+	select {
+	case object := <-p.objects:
+		p.hooks.hits.Add(1)
+		return object
+	default:
+		p.hooks.misses.Add(1)
+		return p.hooks.construct()
+	}
+}
+
+// Put drops the object if the pool is full
+func (p *BoundedPool[T]) Put(object *T) {
+	if object == nil || !p.hooks.accept(object) {
+		return
+	}
+
+	select {
+	case p.objects <- object:
+	default:
+		p.hooks.drops.Add(1)
+	}
+}
+
+func (p *BoundedPool[T]) Stats() PoolStats {
+	return p.hooks.stats()
+}
@@ -0,0 +1,65 @@
+package allocators
+
+import (
+	"bytes"
+	"testing"
+)
+
+const maxBufferCapacity = 1 << 10
+
+func newBuffer() *bytes.Buffer {
+	return new(bytes.Buffer)
+}
+
+func resetBuffer(buffer *bytes.Buffer) {
+	buffer.Reset()
+}
+
+func validateBuffer(buffer *bytes.Buffer) bool {
+	return buffer.Cap() <= maxBufferCapacity
+}
+
+func TestBoundedPool(t *testing.T) {
+	pool, err := NewBoundedPool(1, newBuffer, resetBuffer, WithValidation(validateBuffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer1 := pool.Get()
+	buffer1.WriteString("data")
+	buffer2 := pool.Get()
+	buffer2.Grow(2 * maxBufferCapacity)
+	buffer3 := pool.Get()
+
+	pool.Put(buffer1)
+	pool.Put(buffer2) // too large
+	pool.Put(buffer3) // pool is full
+
+	reused := pool.Get()
+	if reused != buffer1 || reused.Len() != 0 {
+		t.Fatal("buffer is not reused or not reset")
+	}
+
+	if stats := pool.Stats(); stats != (PoolStats{Hits: 1, Misses: 3, Drops: 2}) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestPool(t *testing.T) {
+	if _, err := NewPool[bytes.Buffer](newBuffer, nil); err == nil {
+		t.Fatal("reset function is required")
+	}
+
+	pool, err := NewPool(newBuffer, resetBuffer, WithValidation(validateBuffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := pool.Get()
+	buffer.Grow(2 * maxBufferCapacity)
+	pool.Put(buffer)
+
+	if stats := pool.Stats(); stats.Misses != 1 || stats.Drops != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
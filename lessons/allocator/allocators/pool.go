@@ -0,0 +1,162 @@
+package allocators
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// free slot keeps index of the next free slot
+const linkSize = 4
+
+const noFreeObjects = -1
+
+var (
+	ErrOutOfPool         = errors.New("pointer is outside of the pool")
+	ErrNotObjectBoundary = errors.New("pointer is not on an object boundary")
+	ErrDoubleFree        = errors.New("object is already free")
+)
+
+type PointerError struct {
+	Pointer unsafe.Pointer
+	Err     error
+}
+
+func (e *PointerError) Error() string {
+	return fmt.Sprintf("incorrect pointer %p: %s", e.Pointer, e.Err)
+}
+
+func (e *PointerError) Unwrap() error {
+	return e.Err
+}
+
+type PoolAllocator struct {
+	objectPool []byte
+	allocated  []uint64 // bitmap of allocated objects
+	freeHead   int
+	objectSize int
+	live       int
+}
+
+func NewPoolAllocator(capacity int, objectSize int) (*PoolAllocator, error) {
+	if capacity <= 0 || objectSize < linkSize || capacity%objectSize != 0 {
+		return nil, errors.New("incorrect argumnets")
+	}
+
+	return newPoolAllocator(make([]byte, capacity), objectSize)
+}
+
+// newPoolAllocator makes a pool over the given memory,
+// a tail smaller than an object is not used
+func newPoolAllocator(memory []byte, objectSize int) (*PoolAllocator, error) {
+	if objectSize < linkSize || len(memory) < objectSize || len(memory)/objectSize > math.MaxUint32 {
+		return nil, errors.New("incorrect argumnets")
+	}
+
+	objectsNumber := len(memory) / objectSize
+	allocator := &PoolAllocator{
+		objectPool: memory[:objectsNumber*objectSize],
+		allocated:  make([]uint64, (objectsNumber+63)/64),
+		objectSize: objectSize,
+	}
+
+	allocator.resetMemoryState()
+	return allocator, nil
+}
+
+func (a *PoolAllocator) Allocate() (unsafe.Pointer, error) {
+	if a.freeHead == noFreeObjects {
+		return nil, ErrNotEnoughMemory
+	}
+
+	index := a.freeHead
+	offset := index * a.objectSize
+	next := binary.LittleEndian.Uint32(a.objectPool[offset:])
+	if next == math.MaxUint32 {
+		a.freeHead = noFreeObjects
+	} else {
+		a.freeHead = int(next)
+	}
+
+	a.allocated[index/64] |= 1 << (index % 64)
+	a.live++
+	return unsafe.Pointer(&a.objectPool[offset]), nil
+}
+
+func (a *PoolAllocator) Deallocate(pointer unsafe.Pointer) error {
+	if pointer == nil {
+		return ErrIncorrectPointer
+	}
+
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(a.objectPool)))
+	if uintptr(pointer) < base || uintptr(pointer) >= base+uintptr(len(a.objectPool)) {
+		return &PointerError{Pointer: pointer, Err: ErrOutOfPool}
+	}
+
+	offset := int(uintptr(pointer) - base)
+	if offset%a.objectSize != 0 {
+		return &PointerError{Pointer: pointer, Err: ErrNotObjectBoundary}
+	}
+
+	index := offset / a.objectSize
+	if a.allocated[index/64]&(1<<(index%64)) == 0 {
+		return &PointerError{Pointer: pointer, Err: ErrDoubleFree}
+	}
+
+	a.allocated[index/64] &^= 1 << (index % 64)
+	a.live--
+	a.push(index)
+	return nil
+}
+
+func (a *PoolAllocator) Free() {
+	a.resetMemoryState()
+}
+
+func (a *PoolAllocator) InUse() int {
+	return a.live * a.objectSize
+}
+
+func (a *PoolAllocator) ObjectSize() int {
+	return a.objectSize
+}
+
+func (a *PoolAllocator) resetMemoryState() {
+	clear(a.allocated)
+	a.live = 0
+	a.freeHead = noFreeObjects
+	for index := len(a.objectPool)/a.objectSize - 1; index >= 0; index-- {
+		a.push(index)
+	}
+}
+
+func (a *PoolAllocator) push(index int) {
+	next := uint32(math.MaxUint32)
+	if a.freeHead != noFreeObjects {
+		next = uint32(a.freeHead)
+	}
+
+	binary.LittleEndian.PutUint32(a.objectPool[index*a.objectSize:], next)
+	a.freeHead = index
+}
+
+// PoolAdapter serves any size up to the object size of the pool
+type PoolAdapter struct {
+	*PoolAllocator
+}
+
+var _ Deallocator = PoolAdapter{}
+
+func NewPoolAdapter(allocator *PoolAllocator) PoolAdapter {
+	return PoolAdapter{PoolAllocator: allocator}
+}
+
+func (a PoolAdapter) Allocate(size int) (unsafe.Pointer, error) {
+	if size <= 0 || size > a.objectSize {
+		return nil, ErrIncorrectSize
+	}
+
+	return a.PoolAllocator.Allocate()
+}
@@ -0,0 +1,66 @@
+package allocators
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+func TestPoolAllocatorWithIncorrectPointers(t *testing.T) {
+	allocator, err := NewPoolAllocator(64, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointer, _ := allocator.Allocate()
+
+	var outside int64
+	if err := allocator.Deallocate(unsafe.Pointer(&outside)); !errors.Is(err, ErrOutOfPool) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := allocator.Deallocate(unsafe.Add(pointer, 1)); !errors.Is(err, ErrNotObjectBoundary) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := allocator.Deallocate(pointer); err != nil {
+		t.Fatal(err)
+	}
+
+	var pointerErr *PointerError
+	if err := allocator.Deallocate(pointer); !errors.Is(err, ErrDoubleFree) || !errors.As(err, &pointerErr) || pointerErr.Pointer != pointer {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPoolAllocatorReuse(t *testing.T) {
+	allocator, err := NewPoolAllocator(64, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointers := make([]unsafe.Pointer, 4)
+	for idx := range pointers {
+		pointers[idx], _ = allocator.Allocate()
+	}
+
+	if _, err := allocator.Allocate(); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_ = allocator.Deallocate(pointers[1])
+	_ = allocator.Deallocate(pointers[3])
+
+	// the last released object is reused first
+	if pointer, _ := allocator.Allocate(); pointer != pointers[3] {
+		t.Fatalf("unexpected object: %p", pointer)
+	}
+
+	if pointer, _ := allocator.Allocate(); pointer != pointers[1] {
+		t.Fatalf("unexpected object: %p", pointer)
+	}
+
+	if allocator.InUse() != 64 {
+		t.Fatalf("unexpected memory in use: %d", allocator.InUse())
+	}
+}
@@ -0,0 +1,350 @@
+package allocators
+
+import (
+	"errors"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+const pageSize = 8 << 10
+
+// DefaultSizeClasses is a short version of
+// size classes from runtime/sizeclasses.go
+var DefaultSizeClasses = []int{
+	8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224, 240, 256,
+	288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896, 1024, 1152, 1280,
+	1408, 1536, 1792, 2048,
+}
+
+type SlabOption func(*SlabAllocator)
+
+func WithSizeClasses(sizes []int) SlabOption {
+	return func(allocator *SlabAllocator) {
+		allocator.sizes = sizes
+	}
+}
+
+// WithSlabSize sets size of memory taken from
+// the page cache for every slab, like npages of mspan
+func WithSlabSize(size int) SlabOption {
+	return func(allocator *SlabAllocator) {
+		allocator.slabSize = size
+	}
+}
+
+// WithIdleThreshold sets how long an empty slab
+// stays in its class before return to the page cache
+func WithIdleThreshold(threshold time.Duration) SlabOption {
+	return func(allocator *SlabAllocator) {
+		allocator.idleThreshold = threshold
+	}
+}
+
+func WithClock(now func() time.Time) SlabOption {
+	return func(allocator *SlabAllocator) {
+		allocator.now = now
+	}
+}
+
+type ClassStats struct {
+	Size                  int
+	LiveObjects           int
+	SlabsInUse            int
+	RequestedBytes        int
+	AllocatedBytes        int
+	InternalFragmentation float64 // share of allocated bytes that were not requested
+}
+
+type SlabStats struct {
+	Classes     []ClassStats
+	CachedPages int
+}
+
+type slab struct {
+	pool       *PoolAllocator
+	class      int
+	live       int
+	capacity   int
+	sizes      []uint32 // requested sizes of objects
+	emptySince time.Time
+	available  bool
+}
+
+type sizeClass struct {
+	size      int
+	slabs     []*slab
+	available []*slab // slabs with free objects
+	requested int
+}
+
+var _ Deallocator = (*SlabAllocator)(nil)
+
+// SlabAllocator routes allocations to pools of
+// the nearest size class like mcache with mspans
+type SlabAllocator struct {
+	sizes         []int
+	classes       []sizeClass
+	slabs         []*slab // sorted by address for deallocation
+	pageCache     [][]byte
+	slabSize      int
+	idleThreshold time.Duration
+	now           func() time.Time
+}
+
+func NewSlabAllocator(options ...SlabOption) (*SlabAllocator, error) {
+	allocator := &SlabAllocator{
+		sizes:         DefaultSizeClasses,
+		slabSize:      pageSize,
+		idleThreshold: time.Second,
+		now:           time.Now,
+	}
+
+	for _, option := range options {
+		option(allocator)
+	}
+
+	if len(allocator.sizes) == 0 || allocator.idleThreshold < 0 || allocator.now == nil {
+		return nil, errors.New("incorrect options")
+	}
+
+	allocator.classes = make([]sizeClass, len(allocator.sizes))
+	for idx, size := range allocator.sizes {
+		if size < linkSize || size > allocator.slabSize || (idx > 0 && size <= allocator.sizes[idx-1]) {
+			return nil, errors.New("incorrect size classes")
+		}
+
+		allocator.classes[idx].size = size
+	}
+
+	return allocator, nil
+}
+
+func (a *SlabAllocator) Allocate(size int) (unsafe.Pointer, error) {
+	if size <= 0 {
+		return nil, ErrIncorrectSize
+	}
+
+	classIdx := sort.SearchInts(a.sizes, size)
+	if classIdx == len(a.sizes) {
+		// large objects can be allocated directly from pages
+		return nil, errors.New("size is bigger than the largest size class")
+	}
+
+	class := &a.classes[classIdx]
+	if len(class.available) == 0 {
+		if err := a.addSlab(classIdx); err != nil {
+			return nil, err
+		}
+	}
+
+	current := class.available[len(class.available)-1]
+	pointer, err := current.pool.Allocate()
+	if err != nil {
+		return nil, err
+	}
+
+	current.sizes[current.index(pointer)] = uint32(size)
+	current.live++
+	class.requested += size
+
+	if current.live == current.capacity {
+		current.available = false
+		class.available = class.available[:len(class.available)-1]
+	}
+
+	return pointer, nil
+}
+
+func (a *SlabAllocator) Deallocate(pointer unsafe.Pointer) error {
+	if pointer == nil {
+		return ErrIncorrectPointer
+	}
+
+	current := a.findSlab(pointer)
+	if current == nil {
+		return &PointerError{Pointer: pointer, Err: ErrOutOfPool}
+	}
+
+	if err := current.pool.Deallocate(pointer); err != nil {
+		return err
+	}
+
+	class := &a.classes[current.class]
+	index := current.index(pointer)
+	class.requested -= int(current.sizes[index])
+	current.sizes[index] = 0
+	current.live--
+
+	if !current.available {
+		current.available = true
+		class.available = append(class.available, current)
+	}
+
+	if current.live == 0 {
+		current.emptySince = a.now()
+	}
+
+	return nil
+}
+
+// Scavenge returns slabs which have been empty longer than the idle
+// threshold to the page cache, it is called before a new slab is added
+func (a *SlabAllocator) Scavenge() {
+	now := a.now()
+	for classIdx := range a.classes {
+		class := &a.classes[classIdx]
+		slabs := class.slabs[:0]
+		for _, current := range class.slabs {
+			if current.live != 0 || now.Sub(current.emptySince) < a.idleThreshold {
+				slabs = append(slabs, current)
+				continue
+			}
+
+			a.releaseSlab(current)
+		}
+
+		clear(class.slabs[len(slabs):])
+		class.slabs = slabs
+	}
+}
+
+// Free makes all slabs empty, they are returned
+// to the page cache after the idle threshold
+func (a *SlabAllocator) Free() {
+	now := a.now()
+	for classIdx := range a.classes {
+		class := &a.classes[classIdx]
+		class.available = class.available[:0]
+		class.requested = 0
+		for _, current := range class.slabs {
+			current.pool.Free()
+			clear(current.sizes)
+			current.live = 0
+			current.emptySince = now
+			current.available = true
+			class.available = append(class.available, current)
+		}
+	}
+}
+
+// InUse counts whole objects of size classes, slabs
+// and the page cache are not included
+func (a *SlabAllocator) InUse() int {
+	used := 0
+	for _, class := range a.classes {
+		for _, current := range class.slabs {
+			used += current.live * class.size
+		}
+	}
+
+	return used
+}
+
+func (a *SlabAllocator) Stats() SlabStats {
+	stats := SlabStats{
+		Classes:     make([]ClassStats, 0, len(a.classes)),
+		CachedPages: len(a.pageCache),
+	}
+
+	for _, class := range a.classes {
+		classStats := ClassStats{
+			Size:           class.size,
+			SlabsInUse:     len(class.slabs),
+			RequestedBytes: class.requested,
+		}
+
+		for _, current := range class.slabs {
+			classStats.LiveObjects += current.live
+		}
+
+		classStats.AllocatedBytes = classStats.LiveObjects * class.size
+		if classStats.AllocatedBytes != 0 {
+			wasted := classStats.AllocatedBytes - classStats.RequestedBytes
+			classStats.InternalFragmentation = float64(wasted) / float64(classStats.AllocatedBytes)
+		}
+
+		stats.Classes = append(stats.Classes, classStats)
+	}
+
+	return stats
+}
+
+func (a *SlabAllocator) addSlab(classIdx int) error {
+	a.Scavenge()
+
+	var page []byte
+	if len(a.pageCache) != 0 {
+		page = a.pageCache[len(a.pageCache)-1]
+		a.pageCache = a.pageCache[:len(a.pageCache)-1]
+	} else {
+		page = make([]byte, a.slabSize)
+	}
+
+	class := &a.classes[classIdx]
+	pool, err := newPoolAllocator(page, class.size)
+	if err != nil {
+		return err
+	}
+
+	capacity := a.slabSize / class.size
+	current := &slab{
+		pool:      pool,
+		class:     classIdx,
+		capacity:  capacity,
+		sizes:     make([]uint32, capacity),
+		available: true,
+	}
+
+	class.slabs = append(class.slabs, current)
+	class.available = append(class.available, current)
+
+	position := sort.Search(len(a.slabs), func(i int) bool {
+		return a.slabs[i].base() > current.base()
+	})
+
+	a.slabs = append(a.slabs, nil)
+	copy(a.slabs[position+1:], a.slabs[position:])
+	a.slabs[position] = current
+	return nil
+}
+
+func (a *SlabAllocator) releaseSlab(released *slab) {
+	class := &a.classes[released.class]
+	for idx, current := range class.available {
+		if current == released {
+			class.available = append(class.available[:idx], class.available[idx+1:]...)
+			break
+		}
+	}
+
+	for idx, current := range a.slabs {
+		if current == released {
+			a.slabs = append(a.slabs[:idx], a.slabs[idx+1:]...)
+			break
+		}
+	}
+
+	page := released.pool.objectPool[:a.slabSize]
+	a.pageCache = append(a.pageCache, page)
+}
+
+func (a *SlabAllocator) findSlab(pointer unsafe.Pointer) *slab {
+	position := sort.Search(len(a.slabs), func(i int) bool {
+		return a.slabs[i].base() > uintptr(pointer)
+	}) - 1
+
+	if position < 0 || uintptr(pointer) >= a.slabs[position].base()+uintptr(a.slabSize) {
+		return nil
+	}
+
+	return a.slabs[position]
+}
+
+func (s *slab) base() uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(s.pool.objectPool)))
+}
+
+func (s *slab) index(pointer unsafe.Pointer) int {
+	return int(uintptr(pointer)-s.base()) / s.pool.objectSize
+}
@@ -0,0 +1,91 @@
+package allocators
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestSlabAllocatorSizeClasses(t *testing.T) {
+	allocator, err := NewSlabAllocator(WithSizeClasses([]int{8, 16, 32}), WithSlabSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointer1, _ := allocator.Allocate(9)  // class 16
+	pointer2, _ := allocator.Allocate(16) // class 16
+	if uintptr(pointer2)-uintptr(pointer1) != 16 {
+		t.Fatalf("objects are not in the same slab: %p, %p", pointer1, pointer2)
+	}
+
+	if _, err := allocator.Allocate(33); err == nil {
+		t.Fatal("size above all classes is accepted")
+	}
+
+	expected := ClassStats{
+		Size:                  16,
+		LiveObjects:           2,
+		SlabsInUse:            1,
+		RequestedBytes:        25,
+		AllocatedBytes:        32,
+		InternalFragmentation: 7.0 / 32,
+	}
+
+	stats := allocator.Stats()
+	if len(stats.Classes) != 3 || stats.Classes[1] != expected {
+		t.Fatalf("unexpected stats: %+v", stats.Classes)
+	}
+
+	if stats.Classes[0].SlabsInUse != 0 || stats.Classes[2].SlabsInUse != 0 {
+		t.Fatalf("unexpected slabs: %+v", stats.Classes)
+	}
+
+	if allocator.InUse() != 32 {
+		t.Fatalf("unexpected memory in use: %d", allocator.InUse())
+	}
+
+	var outside int64
+	if err := allocator.Deallocate(unsafe.Pointer(&outside)); !errors.Is(err, ErrOutOfPool) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSlabAllocatorScavenge(t *testing.T) {
+	now := time.Unix(0, 0)
+	allocator, err := NewSlabAllocator(
+		WithSizeClasses([]int{8, 16}),
+		WithSlabSize(64),
+		WithIdleThreshold(time.Second),
+		WithClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pointer, _ := allocator.Allocate(8)
+	if err := allocator.Deallocate(pointer); err != nil {
+		t.Fatal(err)
+	}
+
+	// the empty slab stays in its class until the idle threshold
+	allocator.Scavenge()
+	if stats := allocator.Stats(); stats.Classes[0].SlabsInUse != 1 || stats.CachedPages != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	now = now.Add(time.Second)
+	allocator.Scavenge()
+	if stats := allocator.Stats(); stats.Classes[0].SlabsInUse != 0 || stats.CachedPages != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// the cached page is taken by another class
+	if _, err := allocator.Allocate(16); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := allocator.Stats(); stats.Classes[1].SlabsInUse != 1 || stats.CachedPages != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
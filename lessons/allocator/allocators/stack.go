@@ -0,0 +1,178 @@
+package allocators
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"unsafe"
+)
+
+// header = uvarint(size) + uvarint(previous length) + byte with length of both varints
+const maxHeaderSize = 2*binary.MaxVarintLen64 + 1
+
+var (
+	ErrNotTopOfStack   = errors.New("pointer is not on top of the stack")
+	ErrIncorrectMarker = errors.New("incorrect marker")
+)
+
+type Marker struct {
+	chunk  int
+	length int
+}
+
+type StackAllocator struct {
+	chain chunkChain
+}
+
+func NewStackAllocator(capacity int, options ...ChunkOption) (*StackAllocator, error) {
+	chain, err := newChunkChain(capacity, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StackAllocator{chain: chain}, nil
+}
+
+func (a *StackAllocator) Allocate(size int, align int) (unsafe.Pointer, error) {
+	if size <= 0 {
+		return nil, ErrIncorrectSize
+	}
+
+	if align <= 0 || align&(align-1) != 0 {
+		return nil, ErrIncorrectAlignment
+	}
+
+	offset, headerSize, fits := place(a.chain.chunks[a.chain.current], size, align)
+	if !fits {
+		if size > math.MaxInt-maxHeaderSize-align {
+			return nil, ErrNotEnoughMemory
+		}
+
+		// the largest header and padding which a block can need
+		if err := a.chain.next(maxHeaderSize + align - 1 + size); err != nil {
+			return nil, err
+		}
+
+		offset, headerSize, _ = place(a.chain.chunks[a.chain.current], size, align)
+	}
+
+	chunk := a.chain.chunks[a.chain.current]
+	previousLength := len(chunk)
+
+	var header [maxHeaderSize]byte
+	varintsSize := binary.PutUvarint(header[:], uint64(size))
+	varintsSize += binary.PutUvarint(header[varintsSize:], uint64(previousLength))
+	header[varintsSize] = byte(varintsSize)
+
+	chunk = chunk[:offset+size]
+	copy(chunk[offset-headerSize:], header[:headerSize])
+	a.chain.chunks[a.chain.current] = chunk
+	return unsafe.Pointer(&chunk[offset]), nil
+}
+
+// place returns offset of an aligned block
+// with its header on top of the chunk
+func place(chunk []byte, size int, align int) (int, int, bool) {
+	var header [maxHeaderSize]byte
+	headerSize := binary.PutUvarint(header[:], uint64(size))
+	headerSize += binary.PutUvarint(header[headerSize:], uint64(len(chunk))) + 1
+
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(chunk)))
+	address := base + uintptr(len(chunk)+headerSize)
+	offset := int((address+uintptr(align)-1)&^(uintptr(align)-1) - base)
+	return offset, headerSize, size <= cap(chunk)-offset
+}
+
+func (a *StackAllocator) Deallocate(pointer unsafe.Pointer) error {
+	if pointer == nil {
+		return ErrIncorrectPointer
+	}
+
+	chunk := a.chain.chunks[a.chain.current]
+	base := uintptr(unsafe.Pointer(unsafe.SliceData(chunk)))
+	if uintptr(pointer) <= base || uintptr(pointer) >= base+uintptr(len(chunk)) {
+		return ErrNotTopOfStack
+	}
+
+	offset := int(uintptr(pointer) - base)
+	varintsSize := int(chunk[offset-1])
+	if varintsSize >= offset {
+		return ErrIncorrectPointer
+	}
+
+	varints := chunk[offset-1-varintsSize : offset-1]
+	size, sizeLength := binary.Uvarint(varints)
+	previousLength, previousLengthSize := binary.Uvarint(varints[max(sizeLength, 0):])
+	if sizeLength <= 0 || previousLengthSize <= 0 || sizeLength+previousLengthSize != varintsSize {
+		return ErrIncorrectPointer
+	}
+
+	if offset+int(size) != len(chunk) || int(previousLength) > offset {
+		// only the last allocated block can be deallocated
+		return ErrNotTopOfStack
+	}
+
+	a.chain.chunks[a.chain.current] = chunk[:previousLength]
+	if previousLength == 0 && a.chain.current != 0 {
+		a.chain.current--
+	}
+
+	return nil
+}
+
+// Marker remembers the current top of the stack, all
+// blocks allocated after it can be released at once.
+func (a *StackAllocator) Marker() Marker {
+	return Marker{
+		chunk:  a.chain.current,
+		length: len(a.chain.chunks[a.chain.current]),
+	}
+}
+
+func (a *StackAllocator) FreeToMarker(marker Marker) error {
+	chain := &a.chain
+	// a stale marker must not stretch a chunk over unallocated bytes
+	if marker.chunk < 0 || marker.chunk > chain.current || marker.length < 0 || marker.length > len(chain.chunks[marker.chunk]) {
+		return ErrIncorrectMarker
+	}
+
+	for idx := marker.chunk + 1; idx <= chain.current; idx++ {
+		chain.chunks[idx] = chain.chunks[idx][:0]
+	}
+
+	chain.current = marker.chunk
+	chain.chunks[chain.current] = chain.chunks[chain.current][:marker.length]
+	if marker.length == 0 && chain.current != 0 {
+		chain.current--
+	}
+
+	return nil
+}
+
+func (a *StackAllocator) Free() {
+	a.chain.reset()
+}
+
+func (a *StackAllocator) InUse() int {
+	return a.chain.inUse()
+}
+
+// StackAdapter allocates blocks of the stack allocator with
+// the same alignment, blocks must be deallocated in LIFO order
+type StackAdapter struct {
+	*StackAllocator
+	Align int
+}
+
+var _ Deallocator = StackAdapter{}
+
+func NewStackAdapter(allocator *StackAllocator, align int) StackAdapter {
+	return StackAdapter{
+		StackAllocator: allocator,
+		Align:          align,
+	}
+}
+
+func (a StackAdapter) Allocate(size int) (unsafe.Pointer, error) {
+	return a.StackAllocator.Allocate(size, a.Align)
+}
@@ -0,0 +1,53 @@
+package allocators
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestStackAllocatorWithStaleMarker(t *testing.T) {
+	allocator, err := NewStackAllocator(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(8, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	marker := allocator.Marker()
+	if err := allocator.FreeToMarker(Marker{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the block under the marker is already released
+	if err := allocator.FreeToMarker(marker); !errors.Is(err, ErrIncorrectMarker) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 10; i++ { // some blocks are in the next chunk
+		if _, err := allocator.Allocate(8, 8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := allocator.FreeToMarker(Marker{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if allocator.InUse() != 0 {
+		t.Fatalf("unexpected memory in use: %d", allocator.InUse())
+	}
+}
+
+func TestStackAllocatorWithOverflow(t *testing.T) {
+	allocator, err := NewStackAllocator(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := allocator.Allocate(math.MaxInt-4, 1); !errors.Is(err, ErrNotEnoughMemory) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+// go test -v problem_test.go
+
+import (
+	"errors"
+	"testing"
+
+	"golang_course/lessons/allocator/allocators"
+)
+
+type Data struct {
+	value     int
+	operaions []int
+}
+
+func TestArenaRefusesTypesWithPointers(t *testing.T) {
+	a, err := allocators.NewArena(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer a.Free()
+
+	// Arenas will not allocate all reference types automatically,
+	// so the garbage collector would not see the slice inside of Data
+	data, err := allocators.New[Data](a)
+	if !errors.Is(err, allocators.ErrPointerType) || data != nil {
+		t.Fatalf("unexpected result: %v", err)
+	}
+
+	for _, err := range []error{
+		check[*int](a),
+		check[string](a),
+		check[map[int]int](a),
+		check[chan int](a),
+		check[any](a),
+		check[[4]struct{ name string }](a),
+	} {
+		if !errors.Is(err, allocators.ErrPointerType) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	operations, err := allocators.MakeSlice[int](a, 0, 100)
+	if err != nil || cap(operations) != 100 {
+		t.Fatalf("unexpected result: %v", err)
+	}
+}
+
+func check[T any](a *allocators.Arena) error {
+	_, err := allocators.New[T](a)
+	return err
+}
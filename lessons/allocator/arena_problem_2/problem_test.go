@@ -0,0 +1,40 @@
+package main
+
+// go test -v problem_test.go
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"golang_course/lessons/allocator/allocators"
+)
+
+func TestArenaSliceMovedToHeap(t *testing.T) {
+	mem, err := allocators.NewArena(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mem.Free()
+
+	slice, err := allocators.MakeSlice[int](mem, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slice = append(slice, 1, 2, 3, 4, 5)
+	if !mem.Contains(unsafe.Pointer(unsafe.SliceData(slice))) {
+		t.Fatal("slice is not in the arena")
+	}
+
+	slice = append(slice, 6) // moved to heap
+	if mem.Contains(unsafe.Pointer(unsafe.SliceData(slice))) {
+		t.Fatal("slice is still in the arena")
+	}
+
+	// slices of strings would hide strings from the garbage collector
+	if _, err := allocators.MakeSlice[string](mem, 0, 5); !errors.Is(err, allocators.ErrPointerType) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
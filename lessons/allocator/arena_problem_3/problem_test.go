@@ -0,0 +1,45 @@
+package main
+
+// go test -v problem_test.go
+
+import (
+	"testing"
+
+	"golang_course/lessons/allocator/allocators"
+)
+
+type Data struct {
+	deposit int
+	credit  int
+}
+
+func TestArenaUseAfterFree(t *testing.T) {
+	a, err := allocators.NewArena(1 << 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := allocators.New[Data](a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data.deposit = 100
+	cloned := allocators.Clone(data) // moved to heap
+	a.Free()
+
+	// use after free does not crash, but the data is lost
+	if *data != (Data{}) {
+		t.Fatalf("stale data after free: %+v", *data)
+	}
+
+	if cloned.deposit != 100 {
+		t.Fatalf("cloned data is lost: %+v", *cloned)
+	}
+
+	// memory is reused by the next allocation
+	reused, err := allocators.New[Data](a)
+	if err != nil || reused != data {
+		t.Fatalf("memory is not reused: %v", err)
+	}
+}
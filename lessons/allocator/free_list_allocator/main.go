@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"golang_course/lessons/allocator/allocators"
+)
+
+// the allocator is implemented in the allocators package
+
+func main() {
+	const KB = 1 << 10
+	allocator, err := allocators.NewFreeListAllocator(KB, allocators.BestFit)
+	if err != nil {
+		// handling...
+	}
+
+	defer allocator.Free()
+
+	pointer1, _ := allocator.Allocate(2)
+	pointer2, _ := allocator.Allocate(8)
+	pointer3, _ := allocator.Allocate(4)
+
+	allocators.Store[int16](pointer1, 100)
+	allocators.Store[int64](pointer2, 200)
+	allocators.Store[int32](pointer3, 300)
+
+	value1 := allocators.Load[int16](pointer1)
+	value2 := allocators.Load[int64](pointer2)
+	value3 := allocators.Load[int32](pointer3)
+	fmt.Println("value1:", value1)
+	fmt.Println("value2:", value2)
+	fmt.Println("value3:", value3)
+
+	// any order of deallocation
+	allocator.Deallocate(pointer1)
+	fmt.Printf("%+v\n", allocator.Stats())
+	allocator.Deallocate(pointer3)
+	fmt.Printf("%+v\n", allocator.Stats())
+
+	pointer2, _ = allocator.Realloc(pointer2, 64)
+	fmt.Println("value2 after realloc:", allocators.Load[int64](pointer2))
+	allocator.Deallocate(pointer2)
+	fmt.Printf("%+v\n", allocator.Stats())
+}
@@ -1,57 +1,16 @@
 package main
 
 import (
-	"errors"
 	"fmt"
-	"unsafe"
-)
-
-type LinearAllocator struct {
-	data []byte
-}
-
-func NewLinearAllocator(capacity int) (LinearAllocator, error) {
-	if capacity <= 0 {
-		return LinearAllocator{}, errors.New("incorrect capacity")
-	}
-
-	return LinearAllocator{
-		data: make([]byte, 0, capacity),
-	}, nil
-}
-
-func (a *LinearAllocator) Allocate(size int) (unsafe.Pointer, error) {
-	previousLength := len(a.data)
-	newLength := previousLength + size
-
-	if newLength > cap(a.data) {
-		// can increase capacity
-		return nil, errors.New("not enough memory")
-	}
-
-	a.data = a.data[:newLength]
-	pointer := unsafe.Pointer(&a.data[previousLength])
-	return pointer, nil
-}
 
-// not supported by this kind of allocator
-// func (a *LinearAllocator) Deallocate(pointer unsafe.Pointer) error {}
-
-func (a *LinearAllocator) Free() {
-	a.data = a.data[:0]
-}
-
-func store[T any](pointer unsafe.Pointer, value T) {
-	*(*T)(pointer) = value
-}
+	"golang_course/lessons/allocator/allocators"
+)
 
-func load[T any](pointer unsafe.Pointer) T {
-	return *(*T)(pointer)
-}
+// the allocator is implemented in the allocators package
 
 func main() {
 	const MB = 1 << 20
-	allocator, err := NewLinearAllocator(MB)
+	allocator, err := allocators.NewLinearAllocator(MB, allocators.WithMaxSize(4*MB), allocators.WithGrowthPolicy(allocators.DoubleGrowth))
 	if err != nil {
 		// handling...
 	}
@@ -60,15 +19,20 @@ func main() {
 
 	pointer1, _ := allocator.Allocate(2)
 	pointer2, _ := allocator.Allocate(4)
+	pointer3, _ := allocator.Allocate(MB) // in the second chunk
 
-	store[int16](pointer1, 100)
-	store[int32](pointer2, 200)
+	allocators.Store[int16](pointer1, 100)
+	allocators.Store[int32](pointer2, 200)
+	allocators.Store[int64](pointer3, 300)
 
-	value1 := load[int16](pointer1)
-	value2 := load[int32](pointer2)
+	value1 := allocators.Load[int16](pointer1)
+	value2 := allocators.Load[int32](pointer2)
+	value3 := allocators.Load[int64](pointer3)
 	fmt.Println("value1:", value1)
 	fmt.Println("value2:", value2)
+	fmt.Println("value3:", value3)
 
 	fmt.Println("address1:", pointer1)
 	fmt.Println("address2:", pointer2)
+	fmt.Println("address3:", pointer3)
 }
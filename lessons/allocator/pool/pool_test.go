@@ -5,6 +5,8 @@ package main
 import (
 	"sync"
 	"testing"
+
+	"golang_course/lessons/allocator/allocators"
 )
 
 type Person struct {
@@ -42,6 +44,35 @@ func BenchmarkWithPool(b *testing.B) {
 	}
 }
 
+func BenchmarkWithGenericPool(b *testing.B) {
+	pool, _ := allocators.NewPool(
+		func() *Person { return new(Person) },
+		func(person *Person) { *person = Person{} },
+	)
+
+	for i := 0; i < b.N; i++ {
+		person := pool.Get()
+		person.name = "Ivan"
+		gPerson = person
+		pool.Put(person)
+	}
+}
+
+func BenchmarkWithBoundedPool(b *testing.B) {
+	pool, _ := allocators.NewBoundedPool(
+		1,
+		func() *Person { return new(Person) },
+		func(person *Person) { *person = Person{} },
+	)
+
+	for i := 0; i < b.N; i++ {
+		person := pool.Get()
+		person.name = "Ivan"
+		gPerson = person
+		pool.Put(person)
+	}
+}
+
 func BenchmarkWithoutPool(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		person := &Person{name: "Ivan"}
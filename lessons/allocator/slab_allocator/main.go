@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang_course/lessons/allocator/allocators"
+)
+
+// the allocator is implemented in the allocators package
+
+func main() {
+	allocator, err := allocators.NewSlabAllocator(allocators.WithIdleThreshold(time.Millisecond))
+	if err != nil {
+		// handling...
+	}
+
+	defer allocator.Free()
+
+	pointer1, _ := allocator.Allocate(4)   // class 8
+	pointer2, _ := allocator.Allocate(100) // class 112
+	pointer3, _ := allocator.Allocate(112) // class 112
+
+	allocators.Store[int32](pointer1, 100)
+	allocators.Store[int64](pointer2, 200)
+	allocators.Store[int64](pointer3, 300)
+
+	fmt.Println("value1:", allocators.Load[int32](pointer1))
+	fmt.Println("value2:", allocators.Load[int64](pointer2))
+	fmt.Println("value3:", allocators.Load[int64](pointer3))
+
+	for _, class := range allocator.Stats().Classes {
+		if class.LiveObjects != 0 {
+			fmt.Printf("%+v\n", class)
+		}
+	}
+
+	allocator.Deallocate(pointer1)
+	allocator.Deallocate(pointer2)
+	allocator.Deallocate(pointer3)
+
+	time.Sleep(time.Millisecond)
+	allocator.Scavenge()
+	fmt.Println("cached pages:", allocator.Stats().CachedPages)
+}
@@ -1,72 +1,285 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync/atomic"
+	"net/http"
+	"reflect"
+	"sync"
 	"time"
 )
 
+// errors of the standard library are used to
+// make errors.Is work for code like net/http
+var (
+	Canceled         = context.Canceled
+	DeadlineExceeded = context.DeadlineExceeded
+)
+
+var _ context.Context = (*Context)(nil)
+
+// Context is a cancellable context if it has the done channel,
+// otherwise it keeps a value and delegates the rest to the parent
 type Context struct {
-	done   chan struct{}
-	closed int32
+	parent context.Context
+
+	key   any
+	value any
+
+	deadline    time.Time
+	hasDeadline bool
+
+	mutex    sync.Mutex
+	done     chan struct{}
+	err      error
+	cause    error
+	children map[*Context]struct{}
+	timer    *time.Timer
+	stop     func() bool // stops propagation from a foreign parent
 }
 
-func WithTimeout(parent Context, duration time.Duration) (*Context, func()) {
-	if atomic.LoadInt32(&parent.closed) == 1 {
-		return nil, nil // don't use nil
+func WithCancel(parent context.Context) (*Context, context.CancelFunc) {
+	ctx := newCancelContext(parent)
+	return ctx, func() {
+		ctx.cancel(true, Canceled, nil)
 	}
+}
 
-	ctx := &Context{
-		done: make(chan struct{}),
+// WithCancelCause returns a cancel function which sets
+// the cause of cancellation returned by Cause
+func WithCancelCause(parent context.Context) (*Context, context.CancelCauseFunc) {
+	ctx := newCancelContext(parent)
+	return ctx, func(cause error) {
+		ctx.cancel(true, Canceled, cause)
 	}
+}
+
+func WithDeadline(parent context.Context, deadline time.Time) (*Context, context.CancelFunc) {
+	if parentDeadline, ok := parent.Deadline(); ok && parentDeadline.Before(deadline) {
+		// the parent is cancelled earlier anyway
+		return WithCancel(parent)
+	}
+
+	ctx := newContext(parent)
+	ctx.done = make(chan struct{})
+	ctx.deadline, ctx.hasDeadline = deadline, true
+	ctx.propagate()
 
 	cancel := func() {
-		if atomic.CompareAndSwapInt32(&ctx.closed, 0, 1) {
-			close(ctx.done)
+		ctx.cancel(true, Canceled, nil)
+	}
+
+	duration := time.Until(deadline)
+	if duration <= 0 {
+		ctx.cancel(true, DeadlineExceeded, nil)
+		return ctx, cancel
+	}
+
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+
+	if ctx.err == nil {
+		// the timer has no goroutine until it fires
+		ctx.timer = time.AfterFunc(duration, func() {
+			ctx.cancel(true, DeadlineExceeded, nil)
+		})
+	}
+
+	return ctx, cancel
+}
+
+func WithTimeout(parent context.Context, duration time.Duration) (*Context, context.CancelFunc) {
+	return WithDeadline(parent, time.Now().Add(duration))
+}
+
+func WithValue(parent context.Context, key any, value any) *Context {
+	if key == nil {
+		panic("nil key")
+	}
+
+	if !reflect.TypeOf(key).Comparable() {
+		panic("key is not comparable")
+	}
+
+	ctx := newContext(parent)
+	ctx.key, ctx.value = key, value
+	return ctx
+}
+
+// Cause returns the cause of cancellation of the
+// context or the error if the cause is not set
+func Cause(ctx context.Context) error {
+	for {
+		own, ok := ctx.(*Context)
+		if !ok {
+			return context.Cause(ctx)
+		}
+
+		if own.done != nil {
+			own.mutex.Lock()
+			defer own.mutex.Unlock()
+			return own.cause
+		}
+
+		ctx = own.parent
+	}
+}
+
+func newContext(parent context.Context) *Context {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+
+	return &Context{parent: parent}
+}
+
+func newCancelContext(parent context.Context) *Context {
+	ctx := newContext(parent)
+	ctx.done = make(chan struct{})
+	ctx.propagate()
+	return ctx
+}
+
+// propagate adds the context to children of the closest cancellable
+// ancestor, so a goroutine is needed only for a foreign parent
+func (c *Context) propagate() {
+	done := c.parent.Done()
+	if done == nil {
+		return // never cancelled
+	}
+
+	select {
+	case <-done:
+		c.cancel(false, c.parent.Err(), Cause(c.parent))
+		return
+	default:
+	}
+
+	if parent, ok := cancellableAncestor(c.parent); ok {
+		parent.mutex.Lock()
+		if parent.err != nil {
+			err, cause := parent.err, parent.cause
+			parent.mutex.Unlock()
+			c.cancel(false, err, cause)
+			return
 		}
+
+		if parent.children == nil {
+			parent.children = make(map[*Context]struct{})
+		}
+
+		parent.children[c] = struct{}{}
+		parent.mutex.Unlock()
+		return
 	}
 
-	go func() {
-		timer := time.NewTimer(duration)
-		defer timer.Stop()
+	stop := context.AfterFunc(c.parent, func() {
+		c.cancel(false, c.parent.Err(), Cause(c.parent))
+	})
+
+	c.mutex.Lock()
+	c.stop = stop
+	c.mutex.Unlock()
+}
 
-		select {
-		case <-parent.Done():
-		case <-timer.C:
+func cancellableAncestor(ctx context.Context) (*Context, bool) {
+	for {
+		own, ok := ctx.(*Context)
+		if !ok {
+			return nil, false
 		}
 
-		cancel()
-	}()
+		if own.done != nil {
+			return own, true
+		}
 
-	return ctx, cancel
+		ctx = own.parent
+	}
+}
+
+// cancel closes the context and all its children,
+// only the first call of it has an effect
+func (c *Context) cancel(removeFromParent bool, err error, cause error) {
+	if cause == nil {
+		cause = err
+	}
+
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return
+	}
+
+	c.err, c.cause = err, cause
+	close(c.done)
+
+	children := c.children
+	c.children = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	stop := c.stop
+	c.stop = nil
+	c.mutex.Unlock()
+
+	for child := range children {
+		child.cancel(false, err, cause)
+	}
+
+	if stop != nil {
+		stop()
+	}
+
+	if parent, ok := cancellableAncestor(c.parent); ok && removeFromParent {
+		parent.mutex.Lock()
+		delete(parent.children, c)
+		parent.mutex.Unlock()
+	}
 }
 
 func (c *Context) Done() <-chan struct{} {
+	if c.done == nil {
+		return c.parent.Done()
+	}
+
 	return c.done
 }
 
 func (c *Context) Err() error {
-	select {
-	case <-c.done:
-		return errors.New("context deadline exceeded")
-	default:
-		return nil
+	if c.done == nil {
+		return c.parent.Err()
 	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err
 }
 
 func (c *Context) Deadline() (time.Time, bool) {
-	// not implemented
-	return time.Time{}, false
+	if c.hasDeadline {
+		return c.deadline, true
+	}
+
+	return c.parent.Deadline()
 }
 
-func (c *Context) Value(any) any {
-	// not implemented
-	return nil
+func (c *Context) Value(key any) any {
+	if c.done == nil && c.key == key {
+		return c.value
+	}
+
+	return c.parent.Value(key)
 }
 
+type traceIDKey struct{}
+
 func main() {
-	ctx, cancel := WithTimeout(Context{}, time.Second)
+	parent, cancelParent := WithCancelCause(context.Background())
+	ctx, cancel := WithTimeout(WithValue(parent, traceIDKey{}, "trace-1"), time.Second)
 	defer cancel()
 
 	timer := time.NewTimer(5 * time.Second)
@@ -76,6 +289,13 @@ func main() {
 	case <-timer.C:
 		fmt.Println("finished")
 	case <-ctx.Done():
-		fmt.Println("canceled")
+		fmt.Println("canceled:", ctx.Err(), errors.Is(ctx.Err(), context.DeadlineExceeded))
 	}
+
+	fmt.Println("value:", ctx.Value(traceIDKey{}))
+
+	// the context is accepted by the standard library
+	request, _ := http.NewRequestWithContext(parent, http.MethodGet, "http://localhost:8080", nil)
+	cancelParent(errors.New("shutdown"))
+	fmt.Println("request:", request.Context().Err(), Cause(request.Context()))
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Key is unique per declaration, the identity of a key
+// is its pointer, so keys with equal names never collide
+type Key[T any] struct {
+	name string
+}
+
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) WithValue(ctx context.Context, value T) context.Context {
+	return &valueContext[T]{
+		Context: ctx,
+		key:     k,
+		value:   value,
+	}
+}
+
+func (k *Key[T]) Value(ctx context.Context) (T, bool) {
+	boxed, ok := ctx.Value(k).(box[T])
+	return boxed.value, ok
+}
+
+// MustValue panics if the value is not set
+func (k *Key[T]) MustValue(ctx context.Context) T {
+	value, ok := k.Value(ctx)
+	if !ok {
+		panic(fmt.Sprintf("context: no value for key %s", k))
+	}
+
+	return value
+}
+
+func (k *Key[T]) String() string {
+	// %T of a zero value is <nil> for interface types
+	return fmt.Sprintf("%s(%s)", k.name, reflect.TypeFor[T]())
+}
+
+// Entry is a typed value set in a context chain
+type Entry struct {
+	Key   string
+	Value any
+}
+
+// entriesKey is asked through the whole chain, every
+// typed value adds itself to entries of its parent
+type entriesKey struct{}
+
+// Values lists typed values from the root of the chain,
+// shadowed values of the same key are listed too
+func Values(ctx context.Context) []Entry {
+	entries, _ := ctx.Value(entriesKey{}).([]Entry)
+	return entries
+}
+
+// box is returned for a key instead of the value, a nil
+// value of an interface type would fail the type assertion
+type box[T any] struct {
+	value T
+}
+
+type valueContext[T any] struct {
+	context.Context
+	key   *Key[T]
+	value T
+}
+
+func (c *valueContext[T]) Value(key any) any {
+	switch key {
+	case any(c.key):
+		return box[T]{value: c.value}
+	case entriesKey{}:
+		entries, _ := c.Context.Value(entriesKey{}).([]Entry)
+		return append(entries, Entry{Key: c.key.String(), Value: c.value})
+	default:
+		return c.Context.Value(key)
+	}
+}
+
+func (c *valueContext[T]) String() string {
+	return fmt.Sprintf("%v.WithValue(%s, %v)", c.Context, c.key, c.value)
+}
+
+var (
+	traceIDKey   = NewKey[string]("trace_id")
+	otherTraceID = NewKey[string]("trace_id") // the same name, but another key
+	userIDKey    = NewKey[int]("user_id")
+)
+
+func main() {
+	ctx := traceIDKey.WithValue(context.Background(), "12-21-33")
+	ctx = otherTraceID.WithValue(ctx, "22-22-22")
+	ctx = context.WithValue(ctx, "trace_id", "raw") // untyped values are not listed
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = userIDKey.WithValue(ctx, 100)
+
+	traceID, ok := traceIDKey.Value(ctx)
+	fmt.Println("trace_id:", traceID, ok)
+	fmt.Println("other trace_id:", otherTraceID.MustValue(ctx))
+	fmt.Println("user_id:", userIDKey.MustValue(ctx)+1) // no type assertion
+
+	for _, entry := range Values(ctx) {
+		fmt.Printf("%s = %v\n", entry.Key, entry.Value)
+	}
+
+	_, ok = NewKey[int]("user_id").Value(ctx)
+	fmt.Println("new key:", ok)
+
+	errKey := NewKey[error]("error")
+	fmt.Println("nil error:", errKey.MustValue(errKey.WithValue(ctx, nil)), errKey)
+}
@@ -1,5 +1,3 @@
 module errgroup
 
 go 1.20
-
-require golang.org/x/sync v0.6.0
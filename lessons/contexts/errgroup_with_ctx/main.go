@@ -5,16 +5,51 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
-
-	"golang.org/x/sync/errgroup"
 )
 
+// Group is a minimal version of golang.org/x/sync/errgroup which shows
+// only cancellation on the first error. The lesson is a separate module
+// without dependencies and the homework can not be imported from a test
+// file, so see homework/contexts for limits, TryGo and panics.
+type Group struct {
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+	errOnce sync.Once
+	err     error
+}
+
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+func (g *Group) Go(action func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		if err := action(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	group, groupCtx := errgroup.WithContext(ctx)
+	group, groupCtx := WithContext(ctx)
 	for i := 0; i < 10; i++ {
 		group.Go(func() error {
 			timeout := time.Second * time.Duration(rand.Intn(10))